@@ -11,6 +11,7 @@ type AuthHandlerInterface interface {
 	Register(c *gin.Context)
 	SignIn(c *gin.Context)
 	VerifyLogin(c *gin.Context)
+	CreateWsTicket(c *gin.Context)
 }
 
 type WsHandlerInterface interface {
@@ -30,6 +31,9 @@ type ChatHandlerInterface interface {
 	RemoveParticipant(c *gin.Context)
 	UpdateParticipant(c *gin.Context)
 	EnterToChat(c *gin.Context)
+	PinMessage(c *gin.Context)
+	UnpinMessage(c *gin.Context)
+	GetPinnedMessages(c *gin.Context)
 }
 
 type UserHandlerInterface interface {
@@ -38,11 +42,24 @@ type UserHandlerInterface interface {
 	UpdateUserProfile(c *gin.Context)
 	GetUserProfile(c *gin.Context)
 	GetMyProfile(c *gin.Context)
+	GetPresence(c *gin.Context)
 }
 
 type MessageHandlerInterface interface {
 	SendMessage(c *gin.Context)
 	GetMessages(c *gin.Context)
+	ForwardMessages(c *gin.Context)
+	EditMessage(c *gin.Context)
+	DeleteMessage(c *gin.Context)
+	MarkRead(c *gin.Context)
+	GetThread(c *gin.Context)
+	SearchMessages(c *gin.Context)
+	ScheduleMessage(c *gin.Context)
+	GetScheduledMessages(c *gin.Context)
+	CancelScheduledMessage(c *gin.Context)
+	GetMentions(c *gin.Context)
+	AddReaction(c *gin.Context)
+	RemoveReaction(c *gin.Context)
 }
 
 func CreateRoutes(
@@ -53,9 +70,11 @@ func CreateRoutes(
 	userHandler UserHandlerInterface,
 	m middleware.JwtManagerInterface,
 	repo middleware.SessionRepositoryInterface,
+	tickets middleware.TicketRepositoryInterface,
 ) *gin.Engine {
-	r := gin.Default()
-	r.Use(middleware.LoggingMiddleware())
+	// default gin logger would write tokens of ws connect from query
+	r := gin.New()
+	r.Use(gin.Recovery(), middleware.LoggingMiddleware())
 
 	// auth endpoints
 	r.POST("/register", authHandler.Register)
@@ -78,18 +97,35 @@ func CreateRoutes(
 	r.DELETE("/chat/remove", middleware.AuthMiddleware(m, repo), chatHandler.RemoveParticipant)
 	r.PUT("/chat/participant", middleware.AuthMiddleware(m, repo), chatHandler.UpdateParticipant)
 	r.POST("/chat/enter", middleware.AuthMiddleware(m, repo), chatHandler.EnterToChat)
+	r.POST("/chat/pin", middleware.AuthMiddleware(m, repo), chatHandler.PinMessage)
+	r.DELETE("/chat/pin", middleware.AuthMiddleware(m, repo), chatHandler.UnpinMessage)
+	r.GET("/chat/pins", middleware.AuthMiddleware(m, repo), chatHandler.GetPinnedMessages)
 
 	// message sender handler
 	r.POST("/message/send", middleware.AuthMiddleware(m, repo), messageHandler.SendMessage)
+	r.POST("/message/forward", middleware.AuthMiddleware(m, repo), messageHandler.ForwardMessages)
 	r.GET("/chat/messages", middleware.AuthMiddleware(m, repo), messageHandler.GetMessages)
+	r.GET("/message/thread", middleware.AuthMiddleware(m, repo), messageHandler.GetThread)
+	r.GET("/messages/search", middleware.AuthMiddleware(m, repo), messageHandler.SearchMessages)
+	r.PUT("/message", middleware.AuthMiddleware(m, repo), messageHandler.EditMessage)
+	r.DELETE("/message", middleware.AuthMiddleware(m, repo), messageHandler.DeleteMessage)
+	r.POST("/chat/read", middleware.AuthMiddleware(m, repo), messageHandler.MarkRead)
+	r.POST("/message/schedule", middleware.AuthMiddleware(m, repo), messageHandler.ScheduleMessage)
+	r.GET("/message/scheduled", middleware.AuthMiddleware(m, repo), messageHandler.GetScheduledMessages)
+	r.DELETE("/message/scheduled", middleware.AuthMiddleware(m, repo), messageHandler.CancelScheduledMessage)
+	r.GET("/mentions", middleware.AuthMiddleware(m, repo), messageHandler.GetMentions)
+	r.POST("/message/reaction", middleware.AuthMiddleware(m, repo), messageHandler.AddReaction)
+	r.DELETE("/message/reaction", middleware.AuthMiddleware(m, repo), messageHandler.RemoveReaction)
 
 	// users
 	r.GET("/users", middleware.AuthMiddleware(m, repo), userHandler.GetUsers)
 	r.GET("/users/full", middleware.AuthMiddleware(m, repo), userHandler.GetUsersWithProfiles)
+	r.GET("/users/presence", middleware.AuthMiddleware(m, repo), userHandler.GetPresence)
 	r.GET("/my", middleware.AuthMiddleware(m, repo), userHandler.GetMyProfile)
 	r.GET("/profile", middleware.AuthMiddleware(m, repo), userHandler.GetUserProfile)
 	r.PUT("/profile", middleware.AuthMiddleware(m, repo), userHandler.UpdateUserProfile)
 	// ws handlers
-	r.GET("/connect", middleware.AuthMiddleware(m, repo), wsHandler.Connect)
+	r.POST("/connect/ticket", middleware.AuthMiddleware(m, repo), authHandler.CreateWsTicket)
+	r.GET("/connect", middleware.WsAuthMiddleware(m, repo, tickets), wsHandler.Connect)
 	return r
 }
@@ -9,6 +9,7 @@ import (
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sibhellyx/Messenger/api"
+	"github.com/sibhellyx/Messenger/internal/backplane"
 	"github.com/sibhellyx/Messenger/internal/bot"
 	"github.com/sibhellyx/Messenger/internal/bot/actions"
 	"github.com/sibhellyx/Messenger/internal/config"
@@ -22,6 +23,7 @@ import (
 	authservice "github.com/sibhellyx/Messenger/internal/services/authService"
 	chatservice "github.com/sibhellyx/Messenger/internal/services/chatService"
 	messageservice "github.com/sibhellyx/Messenger/internal/services/messageService"
+	presenceservice "github.com/sibhellyx/Messenger/internal/services/presenceService"
 	userservice "github.com/sibhellyx/Messenger/internal/services/userService"
 	wsservice "github.com/sibhellyx/Messenger/internal/services/wsService"
 	authhandler "github.com/sibhellyx/Messenger/internal/transport/authHandler"
@@ -119,6 +121,7 @@ func (srv *Server) Serve() {
 		redisRepo,
 		time.Duration(srv.cfg.Jwt.AccessTTL*int(time.Minute)),
 		time.Duration(srv.cfg.Jwt.RefreshTTL*int(time.Hour*24)),
+		srv.cfg.Ws.TicketTTL,
 		srv.cfg.Jwt.ActiveSessions,
 	)
 
@@ -130,13 +133,29 @@ func (srv *Server) Serve() {
 	authService.SetBotService(bot)
 
 	slog.Debug("connecting to ws service")
-	wsService := wsservice.NewWsService(hub)
+	var wsBackplane backplane.Backplane
+	if srv.cfg.Ws.Backplane == "memory" {
+		wsBackplane = backplane.NewMemoryBackplane()
+	} else {
+		wsBackplane = backplane.NewRedisBackplane(srv.ctx, redisClient.GetClient(), srv.cfg.Ws.Channel)
+	}
+	defer wsBackplane.Close()
+	wsService := wsservice.NewWsService(hub, wsBackplane, chatRepository, redisRepo, srv.cfg.Ws)
+	if err := wsService.ListenBackplane(); err != nil {
+		slog.Error("failed to subscribe to ws backplane", "error", err)
+		return
+	}
+	wsService.SetReceipts(srv.ctx, messageRepository)
+	authService.SetWsService(wsService)
+	slog.Debug("connecting to presence service")
+	presenceService := presenceservice.NewPresenceService(redisRepo, chatRepository, wsService, srv.cfg.Ws.PresenceTTL)
+	wsService.SetPresence(presenceService)
 	slog.Debug("connecting to chat service")
 	chatService := chatservice.NewChatService(chatRepository, wsService)
 	slog.Debug("connecting to message service")
-	messageService := messageservice.NewMessageService(wsService, producer, messageRepository, chatRepository)
+	messageService := messageservice.NewMessageService(wsService, producer, messageRepository, chatRepository, userRepository, srv.cfg.Msg)
 	slog.Debug("connecting to user service")
-	userService := userservice.NewUserService(userRepository)
+	userService := userservice.NewUserService(userRepository, presenceService)
 
 	slog.Debug("init kafka consumer")
 	consumer := kafka.NewConsumer(srv.cfg.Kafka, messageService)
@@ -149,6 +168,11 @@ func (srv *Server) Serve() {
 	go messageService.StartConsumer(context.Background())
 	defer messageService.StopConsumer()
 
+	// start sending of scheduled messages
+	go messageService.StartScheduler(srv.ctx)
+	// start removing of expired messages
+	go messageService.StartReaper(srv.ctx)
+
 	// init Handlers
 	slog.Debug("connecting to auth handler")
 	authHandler := authhandler.NewAuthHandler(authService)
@@ -156,6 +180,9 @@ func (srv *Server) Serve() {
 	chatHandler := chathandler.NewChatHandler(chatService)
 	slog.Debug("connecting to ws handler")
 	wsHandler := wshandler.NewWsHandler(wsService)
+	slog.Debug("register ws command handlers")
+	commandHandler := wshandler.NewCommandHandler(messageService, wsService)
+	commandHandler.Register(hub)
 	slog.Debug("connecting to message handler")
 	messageHandler := messagehandler.NewMessageHandler(messageService)
 	slog.Debug("connecting to user handler")
@@ -163,7 +190,7 @@ func (srv *Server) Serve() {
 
 	//init routes for messanger
 	slog.Debug("creating routes")
-	routes := api.CreateRoutes(authHandler, chatHandler, wsHandler, messageHandler, userHandler, manager, authRepository)
+	routes := api.CreateRoutes(authHandler, chatHandler, wsHandler, messageHandler, userHandler, manager, authRepository, redisRepo)
 
 	// create http server
 	slog.Debug("init server")
@@ -0,0 +1,44 @@
+package backplane
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type Kind string
+
+const (
+	KindChat         Kind = "chat"          // message for participants of chat
+	KindChats        Kind = "chats"         // message for participants of several chats
+	KindJoin         Kind = "join"          // user added to chat
+	KindLeave        Kind = "leave"         // user removed from chat
+	KindDropChat     Kind = "drop_chat"     // chat deleted
+	KindCloseSession Kind = "close_session" // session deleted, close its socket
+	KindUser         Kind = "user"          // message for sessions of user except Session
+	KindUsers        Kind = "users"         // numbered message for several users
+)
+
+// event shared between all nodes, every node delivers it only to own clients
+type Event struct {
+	Kind      Kind              `json:"kind"`
+	ChatID    uint              `json:"chat_id,omitempty"`
+	ChatIDs   []uint            `json:"chat_ids,omitempty"`
+	UserID    string            `json:"user_id,omitempty"`
+	UserIDs   []string          `json:"user_ids,omitempty"`
+	Session   string            `json:"session,omitempty"`
+	Exclude   string            `json:"exclude,omitempty"`
+	Seqs      map[string]uint64 `json:"seqs,omitempty"`       // userID -> seq of event for user
+	Key       string            `json:"key,omitempty"`        // coalescing key of frame
+	MessageID uint              `json:"message_id,omitempty"` // chat message in event
+	Data      json.RawMessage   `json:"data,omitempty"`
+}
+
+type Handler func(event Event)
+
+type Backplane interface {
+	// send event to all nodes including this one
+	Publish(ctx context.Context, event Event) error
+	// handler called for every event in order of publishing
+	Subscribe(handler Handler) error
+	Close() error
+}
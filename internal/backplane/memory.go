@@ -0,0 +1,42 @@
+package backplane
+
+import (
+	"context"
+	"sync"
+)
+
+// backplane for one node and tests, events delivered synchronously
+type MemoryBackplane struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{}
+}
+
+func (b *MemoryBackplane) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+	return nil
+}
+
+func (b *MemoryBackplane) Subscribe(handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *MemoryBackplane) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = nil
+	return nil
+}
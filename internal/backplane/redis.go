@@ -0,0 +1,92 @@
+package backplane
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// backplane over redis pub/sub, all nodes subscribed to one channel
+type RedisBackplane struct {
+	client  *redis.Client
+	channel string
+
+	mu      sync.Mutex
+	pubsubs []*redis.PubSub
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func NewRedisBackplane(ctx context.Context, client *redis.Client, channel string) *RedisBackplane {
+	ctx, cancel := context.WithCancel(ctx)
+	return &RedisBackplane{
+		client:  client,
+		channel: channel,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (b *RedisBackplane) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal backplane event: %w", err)
+	}
+	if err := b.client.Publish(ctx, b.channel, data).Err(); err != nil {
+		slog.Error("failed publish backplane event", "kind", event.Kind, "error", err)
+		return fmt.Errorf("failed to publish backplane event: %w", err)
+	}
+	return nil
+}
+
+func (b *RedisBackplane) Subscribe(handler Handler) error {
+	pubsub := b.client.Subscribe(b.ctx, b.channel)
+	// wait confirmation, events published after it will not be lost
+	if _, err := pubsub.Receive(b.ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe backplane channel: %w", err)
+	}
+
+	b.mu.Lock()
+	b.pubsubs = append(b.pubsubs, pubsub)
+	b.mu.Unlock()
+
+	go func() {
+		slog.Info("backplane subscription started", "channel", b.channel)
+		defer slog.Info("backplane subscription stopped", "channel", b.channel)
+
+		for msg := range pubsub.Channel() {
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				slog.Error("invalid backplane event", "error", err)
+				continue
+			}
+			handler(event)
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBackplane) Close() error {
+	b.cancel()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs []error
+	for _, pubsub := range b.pubsubs {
+		if err := pubsub.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	b.pubsubs = nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("errors closing backplane: %v", errs)
+	}
+	return nil
+}
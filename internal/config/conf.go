@@ -50,6 +50,19 @@ type WsConfig struct {
 	PongWait       time.Duration `mapstructure:"PONG_WAIT"`        // waiting pong messages
 	PingPeriod     time.Duration `mapstructure:"PING_PERIOD"`      // period send ping
 	MaxMessageSize int64         `mapstructure:"MAX_MESSAGE_SIZE"` // max size message
+	PresenceTTL    time.Duration `mapstructure:"PRESENCE_TTL"`     // user is offline if no heartbeat during ttl
+	TypingThrottle time.Duration `mapstructure:"TYPING_THROTTLE"`  // min interval between typing events of user in chat
+	TypingTimeout  time.Duration `mapstructure:"TYPING_TIMEOUT"`   // typing stops without refresh during timeout
+	Backplane      string        `mapstructure:"WS_BACKPLANE"`     // redis to share events between nodes, memory for single node
+	Channel        string        `mapstructure:"WS_CHANNEL"`       // redis channel of backplane
+	ReplaySize     int64         `mapstructure:"REPLAY_SIZE"`      // max events kept per user for replay after reconnect
+	ReplayTTL      time.Duration `mapstructure:"REPLAY_TTL"`       // events of user are dropped after ttl without new ones
+
+	SendBuffer         int           `mapstructure:"SEND_BUFFER"`          // frames queued for one client
+	SlowConsumerPolicy string        `mapstructure:"SLOW_CONSUMER_POLICY"` // drop_oldest, coalesce or disconnect when buffer is full
+	SlowConsumerGrace  time.Duration `mapstructure:"SLOW_CONSUMER_GRACE"`  // buffer may stay full during grace before disconnect
+
+	TicketTTL time.Duration `mapstructure:"WS_TICKET_TTL"` // single use ticket for connect from browser
 }
 
 type KafkaConfig struct {
@@ -80,6 +93,19 @@ type KafkaConfig struct {
 	MaxQueueSize int `mapstructure:"KAFKA_MAX_QUEUE_SIZE"`
 }
 
+type MessageConfig struct {
+	EditWindow time.Duration `mapstructure:"MESSAGE_EDIT_WINDOW"` // author can edit message during window after sending
+
+	ScheduleInterval     time.Duration `mapstructure:"SCHEDULE_INTERVAL"`      // how often scheduler looks for due messages
+	ScheduleBatch        int           `mapstructure:"SCHEDULE_BATCH"`         // max messages sent by one scheduler run
+	ScheduleMaxAhead     time.Duration `mapstructure:"SCHEDULE_MAX_AHEAD"`     // how far in future message can be scheduled
+	ScheduleMaxAttempts  int           `mapstructure:"SCHEDULE_MAX_ATTEMPTS"`  // failed send is retried until attempts are over
+	ScheduleClaimTimeout time.Duration `mapstructure:"SCHEDULE_CLAIM_TIMEOUT"` // message left in sending is claimed again after timeout
+
+	ReaperInterval time.Duration `mapstructure:"REAPER_INTERVAL"` // how often expired messages are removed
+	ReaperBatch    int           `mapstructure:"REAPER_BATCH"`    // max messages removed by one reaper run
+}
+
 type Config struct {
 	Env   EnvConfig
 	Bot   BotConfig
@@ -90,6 +116,7 @@ type Config struct {
 	Auth  AuthConfig
 	Ws    WsConfig
 	Kafka KafkaConfig
+	Msg   MessageConfig
 }
 
 func LoadConfig() (Config, error) {
@@ -166,9 +193,17 @@ func LoadConfig() (Config, error) {
 	if err := v.Unmarshal(&cfg.Ws); err != nil {
 		return Config{}, fmt.Errorf("failed to unmarshal websocket config: %w", err)
 	}
+	switch cfg.Ws.SlowConsumerPolicy {
+	case "drop_oldest", "coalesce", "disconnect":
+	default:
+		return Config{}, fmt.Errorf("unknown slow consumer policy: %q", cfg.Ws.SlowConsumerPolicy)
+	}
 	if err := v.Unmarshal(&cfg.Kafka); err != nil {
 		return Config{}, fmt.Errorf("failed to unmarshal kafka config: %w", err)
 	}
+	if err := v.Unmarshal(&cfg.Msg); err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal message config: %w", err)
+	}
 
 	// Log important configuration (without sensitive data)
 	slog.Info("configuration loaded successfully",
@@ -189,7 +224,18 @@ func LoadConfig() (Config, error) {
 		"write_wait", cfg.Ws.WriteWait,
 		"pong_wait", cfg.Ws.PongWait,
 		"ping_period", cfg.Ws.PingPeriod,
-		"max_message_size", cfg.Ws.MaxMessageSize)
+		"max_message_size", cfg.Ws.MaxMessageSize,
+		"presence_ttl", cfg.Ws.PresenceTTL,
+		"typing_throttle", cfg.Ws.TypingThrottle,
+		"typing_timeout", cfg.Ws.TypingTimeout,
+		"backplane", cfg.Ws.Backplane,
+		"channel", cfg.Ws.Channel,
+		"replay_size", cfg.Ws.ReplaySize,
+		"replay_ttl", cfg.Ws.ReplayTTL,
+		"send_buffer", cfg.Ws.SendBuffer,
+		"slow_consumer_policy", cfg.Ws.SlowConsumerPolicy,
+		"slow_consumer_grace", cfg.Ws.SlowConsumerGrace,
+		"ticket_ttl", cfg.Ws.TicketTTL)
 
 	slog.Info("kafka configuration",
 		"brokers", cfg.Kafka.Brokers,
@@ -198,6 +244,16 @@ func LoadConfig() (Config, error) {
 		"max_retry", cfg.Kafka.MaxRetry,
 		"batch_size", cfg.Kafka.BatchSize)
 
+	slog.Info("message configuration",
+		"edit_window", cfg.Msg.EditWindow,
+		"schedule_interval", cfg.Msg.ScheduleInterval,
+		"schedule_batch", cfg.Msg.ScheduleBatch,
+		"schedule_max_ahead", cfg.Msg.ScheduleMaxAhead,
+		"schedule_max_attempts", cfg.Msg.ScheduleMaxAttempts,
+		"schedule_claim_timeout", cfg.Msg.ScheduleClaimTimeout,
+		"reaper_interval", cfg.Msg.ReaperInterval,
+		"reaper_batch", cfg.Msg.ReaperBatch)
+
 	return cfg, nil
 }
 
@@ -233,7 +289,18 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("WRITE_WAIT", 10*time.Second)
 	v.SetDefault("PONG_WAIT", 60*time.Second)
 	v.SetDefault("PING_PERIOD", 54*time.Second)
-	v.SetDefault("MAX_MESSAGE_SIZE", 512)
+	v.SetDefault("MAX_MESSAGE_SIZE", 8192)
+	v.SetDefault("PRESENCE_TTL", 90*time.Second)
+	v.SetDefault("TYPING_THROTTLE", 2*time.Second)
+	v.SetDefault("TYPING_TIMEOUT", 6*time.Second)
+	v.SetDefault("WS_BACKPLANE", "redis")
+	v.SetDefault("WS_CHANNEL", "messenger-ws")
+	v.SetDefault("REPLAY_SIZE", 500)
+	v.SetDefault("REPLAY_TTL", 24*time.Hour)
+	v.SetDefault("SEND_BUFFER", 256)
+	v.SetDefault("SLOW_CONSUMER_POLICY", "disconnect")
+	v.SetDefault("SLOW_CONSUMER_GRACE", 5*time.Second)
+	v.SetDefault("WS_TICKET_TTL", 30*time.Second)
 
 	// Kafka defaults
 	v.SetDefault("KAFKA_BROKERS", []string{"localhost:9092"})
@@ -252,6 +319,16 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("KAFKA_MAX_WAIT_TIME", 250)
 	v.SetDefault("KAFKA_WRITE_TIMEOUT", 10)
 	v.SetDefault("KAFKA_READ_TIMEOUT", 10)
+
+	// Message defaults
+	v.SetDefault("MESSAGE_EDIT_WINDOW", 48*time.Hour)
+	v.SetDefault("SCHEDULE_INTERVAL", 5*time.Second)
+	v.SetDefault("SCHEDULE_BATCH", 100)
+	v.SetDefault("SCHEDULE_MAX_AHEAD", 365*24*time.Hour)
+	v.SetDefault("SCHEDULE_MAX_ATTEMPTS", 5)
+	v.SetDefault("SCHEDULE_CLAIM_TIMEOUT", time.Minute)
+	v.SetDefault("REAPER_INTERVAL", 10*time.Second)
+	v.SetDefault("REAPER_BATCH", 500)
 }
 
 func (cfg Config) GetDbString() string {
@@ -3,11 +3,15 @@ package chatrepo
 import (
 	"errors"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/sibhellyx/Messenger/internal/models/chaterrors"
 	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/response"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ChatRepository struct {
@@ -209,6 +213,37 @@ func (r *ChatRepository) GetUserChats(userID uint) ([]*entity.Chat, error) {
 	return chats, nil
 }
 
+func (r *ChatRepository) GetUserChatIds(userID uint) ([]uint, error) {
+	slog.Debug("getting user chat ids", "user_id", userID)
+
+	var chatIDs []uint
+	err := r.db.Model(&entity.ChatParticipant{}).
+		Where("user_id = ? AND deleted_at IS NULL", userID).
+		Pluck("chat_id", &chatIDs).Error
+
+	if err != nil {
+		slog.Error("failed to get user chat IDs", "user_id", userID, "error", err)
+		return nil, chaterrors.ErrFailedGetChats
+	}
+	return chatIDs, nil
+}
+
+func (r *ChatRepository) GetChatsParticipantIds(chatIDs []uint) ([]uint, error) {
+	slog.Debug("getting participant ids of chats", "chat_ids", chatIDs)
+
+	var userIDs []uint
+	err := r.db.Model(&entity.ChatParticipant{}).
+		Where("chat_id IN ? AND deleted_at IS NULL", chatIDs).
+		Distinct().
+		Pluck("user_id", &userIDs).Error
+
+	if err != nil {
+		slog.Error("failed to get participant IDs of chats", "chat_ids", chatIDs, "error", err)
+		return nil, chaterrors.ErrFailedGetParticipants
+	}
+	return userIDs, nil
+}
+
 func (r *ChatRepository) GetChats() ([]*entity.Chat, error) {
 	slog.Debug("getting all chats")
 	var chats []*entity.Chat
@@ -340,25 +375,97 @@ func (r *ChatRepository) UpdateParticipant(participant *entity.ChatParticipant)
 	return nil
 }
 
-func (r *ChatRepository) GetMessagesByChatId(chatId uint, since *time.Time) ([]*entity.Message, error) {
-	slog.Debug("get messages by chat_id", "chat_id", chatId, "since", since)
+// read pointer only moves forward, returns false if it is already at or after message
+func (r *ChatRepository) AdvanceLastRead(userID, chatID, messageID uint) (bool, error) {
+	slog.Debug("advancing read pointer", "chat_id", chatID, "user_id", userID, "message_id", messageID)
+
+	result := r.db.Model(&entity.ChatParticipant{}).
+		Where("user_id = ? AND chat_id = ? AND deleted_at IS NULL", userID, chatID).
+		Where("last_read_message_id IS NULL OR last_read_message_id < ?", messageID).
+		Update("last_read_message_id", messageID)
+	if result.Error != nil {
+		slog.Error("failed to advance read pointer",
+			"chat_id", chatID,
+			"user_id", userID,
+			"error", result.Error)
+		return false, chaterrors.ErrFailedUpdateParticipant
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// unread messages of other users in every chat of user, chats without unread are absent
+func (r *ChatRepository) GetUnreadCounts(userID uint) (map[uint]int64, error) {
+	slog.Debug("getting unread counts", "user_id", userID)
+
+	var rows []struct {
+		ChatID uint
+		Unread int64
+	}
+	err := r.db.Table("chat_participants AS p").
+		Select("p.chat_id AS chat_id, COUNT(m.id) AS unread").
+		Joins("JOIN messages AS m ON m.chat_id = p.chat_id AND m.deleted_at IS NULL "+
+			"AND m.user_id <> p.user_id AND m.id > COALESCE(p.last_read_message_id, 0)").
+		Where("p.user_id = ? AND p.deleted_at IS NULL", userID).
+		Group("p.chat_id").
+		Scan(&rows).Error
+	if err != nil {
+		slog.Error("failed to get unread counts", "user_id", userID, "error", err)
+		return nil, chaterrors.ErrFailedGetChats
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ChatID] = row.Unread
+	}
+	return counts, nil
+}
+
+// page of chat history in ascending order, uses (chat_id, id) index.
+// returns cursor for next page in same direction, nil if there are no more messages
+func (r *ChatRepository) GetMessagesByChatId(chatId uint, since *time.Time, page request.MessagesPage) ([]*entity.Message, *uint, error) {
+	slog.Debug("get messages by chat_id", "chat_id", chatId, "since", since, "before_id", page.BeforeID, "after_id", page.AfterID, "limit", page.Limit)
 	var messages []*entity.Message
 
-	query := r.db.Where("chat_id = ? AND deleted_at IS NULL", chatId)
+	// expired messages are hidden before reaper removes them
+	query := r.db.Where("chat_id = ? AND deleted_at IS NULL", chatId).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
 
 	if since != nil {
 		query = query.Where("created_at > ?", since)
 	}
 
-	err := query.Order("created_at ASC").
+	forward := page.Forward(since != nil)
+	if page.BeforeID != 0 {
+		query = query.Where("id < ?", page.BeforeID)
+	}
+	if page.AfterID != 0 {
+		query = query.Where("id > ?", page.AfterID)
+	}
+	if forward {
+		query = query.Order("id ASC")
+	} else {
+		query = query.Order("id DESC")
+	}
+
+	// one extra message shows that next page exists
+	err := query.Limit(page.Limit + 1).
 		Find(&messages).Error
 
 	if err != nil {
 		slog.Error("error failed get messages by chat_id", "chat_id", chatId, "since", since, "err", err)
-		return nil, errors.New("failed get messages")
+		return nil, nil, errors.New("failed get messages")
+	}
+
+	var next *uint
+	if len(messages) > page.Limit {
+		messages = messages[:page.Limit]
+		next = &messages[len(messages)-1].ID
+	}
+	if !forward {
+		slices.Reverse(messages)
 	}
 
-	return messages, nil
+	return messages, next, nil
 }
 
 func (r *ChatRepository) UserExist(userID uint) bool {
@@ -417,3 +524,65 @@ func (r *ChatRepository) CheckChatDirected(chatID uint) bool {
 
 	return typeChat != entity.ChatTypeDirect
 }
+
+func (r *ChatRepository) MessageInChat(chatID, messageID uint) bool {
+	var count int64
+	r.db.Model(&entity.Message{}).Where("id = ? AND chat_id = ?", messageID, chatID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+// returns false if message already pinned
+func (r *ChatRepository) PinMessage(pin *entity.ChatPin) (bool, error) {
+	slog.Debug("pin message", "chat_id", pin.ChatID, "message_id", pin.MessageID, "pinned_by", pin.PinnedBy)
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(pin)
+	if result.Error != nil {
+		slog.Error("failed to pin message", "chat_id", pin.ChatID, "message_id", pin.MessageID, "error", result.Error)
+		return false, chaterrors.ErrFailedPinMessage
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// returns false if message was not pinned
+func (r *ChatRepository) UnpinMessage(chatID, messageID uint) (bool, error) {
+	slog.Debug("unpin message", "chat_id", chatID, "message_id", messageID)
+	result := r.db.Where("chat_id = ? AND message_id = ?", chatID, messageID).Delete(&entity.ChatPin{})
+	if result.Error != nil {
+		slog.Error("failed to unpin message", "chat_id", chatID, "message_id", messageID, "error", result.Error)
+		return false, chaterrors.ErrFailedUnpinMessage
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// pinned messages of chat, last pinned first, deleted messages are skipped
+func (r *ChatRepository) GetPinnedMessages(chatID uint) ([]*response.PinnedMessage, error) {
+	slog.Debug("getting pinned messages", "chat_id", chatID)
+
+	var rows []struct {
+		entity.Message
+		PinnedBy uint
+		PinnedAt time.Time
+	}
+	err := r.db.Table("chat_pins AS p").
+		Select("m.*, p.pinned_by AS pinned_by, p.created_at AS pinned_at").
+		Joins("JOIN messages AS m ON m.id = p.message_id AND m.deleted_at IS NULL").
+		Where("p.chat_id = ?", chatID).
+		Where("m.expires_at IS NULL OR m.expires_at > ?", time.Now()).
+		Order("p.created_at DESC").
+		Scan(&rows).Error
+	if err != nil {
+		slog.Error("failed to get pinned messages", "chat_id", chatID, "error", err)
+		return nil, chaterrors.ErrFailedGetPins
+	}
+
+	pins := make([]*response.PinnedMessage, 0, len(rows))
+	for i := range rows {
+		pins = append(pins, &response.PinnedMessage{
+			Message:  &rows[i].Message,
+			PinnedBy: rows[i].PinnedBy,
+			PinnedAt: rows[i].PinnedAt,
+		})
+	}
+	return pins, nil
+}
@@ -0,0 +1,330 @@
+package chatrepo
+
+import (
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sibhellyx/Messenger/internal/db/migrate"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/response"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// repository on database from TEST_DATABASE_DSN, test is skipped without it.
+// tests create own users and chats, so rows of other runs don't interfere
+func newTestRepository(t *testing.T) (*ChatRepository, *gorm.DB) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  dsn,
+		PreferSimpleProtocol: true,
+	}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrateOnce.Do(func() { migrateErr = migrate.Migrate(db) })
+	if migrateErr != nil {
+		t.Fatal(migrateErr)
+	}
+	return NewChatRepository(db), db
+}
+
+// chat with new users as participants
+func newTestChat(t *testing.T, db *gorm.DB, users int) (*entity.Chat, []uint) {
+	t.Helper()
+	ids := make([]uint, 0, users)
+	for i := 0; i < users; i++ {
+		user := entity.User{Name: "test", Surname: "test", Tgname: "test_" + uuid.NewString(), Password: "test"}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	chat := &entity.Chat{Name: "test", Type: entity.ChatTypeGroup, CreatedBy: ids[0]}
+	if err := db.Create(chat).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if err := db.Create(&entity.ChatParticipant{ChatID: chat.ID, UserID: id, Role: entity.RoleMember}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chat, ids
+}
+
+func newTestMessage(t *testing.T, db *gorm.DB, chatID, userID uint, content string) *entity.Message {
+	t.Helper()
+	message := &entity.Message{ChatID: chatID, UserID: userID, Type: entity.MessageTypeText, Content: content, Status: entity.MessageStatusSent}
+	if err := db.Create(message).Error; err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestGetMessagesByChatIdPages(t *testing.T) {
+	r, db := newTestRepository(t)
+	chat, users := newTestChat(t, db, 1)
+
+	var messages []*entity.Message
+	for i := 0; i < 5; i++ {
+		messages = append(messages, newTestMessage(t, db, chat.ID, users[0], "hello"))
+		if i == 1 {
+			// hidden messages between second and third one
+			expired := newTestMessage(t, db, chat.ID, users[0], "expired")
+			db.Model(expired).Update("expires_at", time.Now().Add(-time.Minute))
+			deleted := newTestMessage(t, db, chat.ID, users[0], "deleted")
+			db.Delete(deleted)
+		}
+	}
+	id := func(i int) uint { return messages[i].ID }
+
+	tests := []struct {
+		name     string
+		page     request.MessagesPage
+		want     []int // indexes of messages in page
+		wantNext int   // index of cursor message, -1 if there is no next page
+	}{
+		{name: "latest", page: request.MessagesPage{Limit: 2}, want: []int{3, 4}, wantNext: 3},
+		{name: "before cursor", page: request.MessagesPage{BeforeID: id(3), Limit: 2}, want: []int{1, 2}, wantNext: 1},
+		{name: "last page", page: request.MessagesPage{BeforeID: id(1), Limit: 2}, want: []int{0}, wantNext: -1},
+		{name: "after cursor", page: request.MessagesPage{AfterID: id(0), Limit: 2}, want: []int{1, 2}, wantNext: 2},
+		{name: "newest page", page: request.MessagesPage{AfterID: id(2), Limit: 2}, want: []int{3, 4}, wantNext: -1},
+		{name: "whole chat", page: request.MessagesPage{Limit: 10}, want: []int{0, 1, 2, 3, 4}, wantNext: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, next, err := r.GetMessagesByChatId(chat.ID, nil, tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, want []uint
+			for _, message := range page {
+				got = append(got, message.ID)
+			}
+			for _, i := range tt.want {
+				want = append(want, id(i))
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("page = %v, want %v", got, want)
+			}
+
+			switch {
+			case tt.wantNext < 0 && next != nil:
+				t.Errorf("next = %d, want nil", *next)
+			case tt.wantNext >= 0 && (next == nil || *next != id(tt.wantNext)):
+				t.Errorf("next = %v, want %d", next, id(tt.wantNext))
+			}
+		})
+	}
+}
+
+func TestReadPointerAndUnreadCounts(t *testing.T) {
+	r, db := newTestRepository(t)
+	chat, users := newTestChat(t, db, 2)
+	reader, author := users[0], users[1]
+
+	var messages []*entity.Message
+	for i := 0; i < 4; i++ {
+		messages = append(messages, newTestMessage(t, db, chat.ID, author, "hello"))
+	}
+	// own messages and deleted ones are not unread
+	newTestMessage(t, db, chat.ID, reader, "mine")
+	db.Delete(newTestMessage(t, db, chat.ID, author, "deleted"))
+
+	tests := []struct {
+		name        string
+		readUpTo    int // index of message, -1 reads nothing
+		wantMoved   bool
+		wantUnread  int64
+		wantPointer int
+	}{
+		{name: "nothing read", readUpTo: -1, wantUnread: 4, wantPointer: -1},
+		{name: "read second", readUpTo: 1, wantMoved: true, wantUnread: 2, wantPointer: 1},
+		{name: "same message again", readUpTo: 1, wantMoved: false, wantUnread: 2, wantPointer: 1},
+		{name: "older message", readUpTo: 0, wantMoved: false, wantUnread: 2, wantPointer: 1},
+		{name: "read all", readUpTo: 3, wantMoved: true, wantUnread: 0, wantPointer: 3},
+	}
+
+	// steps run in order on same chat
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.readUpTo >= 0 {
+				moved, err := r.AdvanceLastRead(reader, chat.ID, messages[tt.readUpTo].ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if moved != tt.wantMoved {
+					t.Errorf("moved = %v, want %v", moved, tt.wantMoved)
+				}
+			}
+
+			counts, err := r.GetUnreadCounts(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if counts[chat.ID] != tt.wantUnread {
+				t.Errorf("unread = %d, want %d", counts[chat.ID], tt.wantUnread)
+			}
+			if _, ok := counts[chat.ID]; ok && tt.wantUnread == 0 {
+				t.Error("chat without unread is in counts")
+			}
+
+			participant, err := r.GetParticipantByUserIdAndChatId(reader, chat.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pointer := participant.LastReadMessageID
+			if tt.wantPointer < 0 && pointer != nil {
+				t.Errorf("read pointer = %d, want nil", *pointer)
+			}
+			if tt.wantPointer >= 0 && (pointer == nil || *pointer != messages[tt.wantPointer].ID) {
+				t.Errorf("read pointer = %v, want %d", pointer, messages[tt.wantPointer].ID)
+			}
+		})
+	}
+}
+
+func TestPinnedMessages(t *testing.T) {
+	r, db := newTestRepository(t)
+	chat, users := newTestChat(t, db, 1)
+	other, _ := newTestChat(t, db, 1)
+
+	first := newTestMessage(t, db, chat.ID, users[0], "first")
+	second := newTestMessage(t, db, chat.ID, users[0], "second")
+	deleted := newTestMessage(t, db, chat.ID, users[0], "deleted")
+	foreign := newTestMessage(t, db, other.ID, users[0], "foreign")
+
+	pin := func(chatID, messageID uint) bool {
+		t.Helper()
+		pinned, err := r.PinMessage(&entity.ChatPin{ChatID: chatID, MessageID: messageID, PinnedBy: users[0]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pinned
+	}
+	unpin := func(messageID uint) bool {
+		t.Helper()
+		unpinned, err := r.UnpinMessage(chat.ID, messageID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return unpinned
+	}
+
+	steps := []struct {
+		name string
+		run  func() bool
+		want bool
+		pins []uint // last pinned first
+	}{
+		{name: "pin message", run: func() bool { return pin(chat.ID, first.ID) }, want: true, pins: []uint{first.ID}},
+		{name: "pin again", run: func() bool { return pin(chat.ID, first.ID) }, want: false, pins: []uint{first.ID}},
+		{name: "pin second", run: func() bool { return pin(chat.ID, second.ID) }, want: true, pins: []uint{second.ID, first.ID}},
+		{name: "pin of other chat", run: func() bool { return pin(other.ID, foreign.ID) }, want: true, pins: []uint{second.ID, first.ID}},
+		{
+			name: "deleted message is skipped",
+			run: func() bool {
+				pinned := pin(chat.ID, deleted.ID)
+				db.Delete(deleted)
+				return pinned
+			},
+			want: true,
+			pins: []uint{second.ID, first.ID},
+		},
+		{name: "unpin", run: func() bool { return unpin(second.ID) }, want: true, pins: []uint{first.ID}},
+		{name: "unpin not pinned", run: func() bool { return unpin(second.ID) }, want: false, pins: []uint{first.ID}},
+	}
+
+	// steps run in order on same chat
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if got := step.run(); got != step.want {
+				t.Errorf("changed = %v, want %v", got, step.want)
+			}
+
+			pins, err := r.GetPinnedMessages(chat.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for _, p := range pins {
+				got = append(got, p.Message.ID)
+				if p.PinnedBy != users[0] || p.PinnedAt.IsZero() {
+					t.Errorf("pin of %d by %d at %v", p.Message.ID, p.PinnedBy, p.PinnedAt)
+				}
+			}
+			if !slices.Equal(got, step.pins) {
+				t.Errorf("pins = %v, want %v", got, step.pins)
+			}
+		})
+	}
+}
+
+func TestExpiredMessagesAreHidden(t *testing.T) {
+	r, db := newTestRepository(t)
+	chat, users := newTestChat(t, db, 1)
+
+	kept := newTestMessage(t, db, chat.ID, users[0], "kept")
+	expired := newTestMessage(t, db, chat.ID, users[0], "expired")
+	if err := db.Model(expired).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []*entity.Message{kept, expired} {
+		if _, err := r.PinMessage(&entity.ChatPin{ChatID: chat.ID, MessageID: message.ID, PinnedBy: users[0]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		message *entity.Message
+		want    bool
+	}{
+		{name: "kept message", message: kept, want: true},
+		{name: "expired message", message: expired, want: false},
+	}
+
+	pins, err := r.GetPinnedMessages(chat.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, _, err := r.GetMessagesByChatId(chat.ID, nil, request.MessagesPage{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.MessageInChat(chat.ID, tt.message.ID); got != tt.want {
+				t.Errorf("MessageInChat() = %v, want %v", got, tt.want)
+			}
+			pinned := slices.ContainsFunc(pins, func(p *response.PinnedMessage) bool { return p.Message.ID == tt.message.ID })
+			if pinned != tt.want {
+				t.Errorf("in pins = %v, want %v", pinned, tt.want)
+			}
+			inHistory := slices.ContainsFunc(history, func(m *entity.Message) bool { return m.ID == tt.message.ID })
+			if inHistory != tt.want {
+				t.Errorf("in history = %v, want %v", inHistory, tt.want)
+			}
+		})
+	}
+}
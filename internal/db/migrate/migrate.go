@@ -20,6 +20,12 @@ func Migrate(db *gorm.DB) error {
 		{&entity.Chat{}, "chats"},
 		{&entity.Message{}, "messages"},
 		{&entity.ChatParticipant{}, "chat_participants"},
+		{&entity.MessageEdit{}, "message_edits"},
+		{&entity.MessageReaction{}, "message_reactions"},
+		{&entity.ChatPin{}, "chat_pins"},
+		{&entity.ScheduledMessage{}, "scheduled_messages"},
+		{&entity.MessageReceipt{}, "message_receipts"},
+		{&entity.MessageMention{}, "message_mentions"},
 	}
 
 	for i, migration := range migrationOrder {
@@ -42,6 +48,75 @@ func Migrate(db *gorm.DB) error {
 		slog.Info("successfully migrated table", "table", migration.tableName)
 	}
 
+	// columns which are not part of entities
+	columns := []struct {
+		name string
+		sql  string
+	}{
+		// search vector of message content, 'simple' config works for any language
+		{"messages.content_tsv", "ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_tsv tsvector " +
+			"GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED"},
+	}
+
+	for _, column := range columns {
+		if err := db.Exec(column.sql).Error; err != nil {
+			slog.Error("failed to create column", "column", column.name, "error", err)
+			return err
+		}
+		slog.Info("successfully created column", "column", column.name)
+	}
+
+	// existing rows which would break indexes below
+	fixes := []struct {
+		name string
+		sql  string
+	}{
+		// sends before idx_messages_user_id_client_id could repeat client id,
+		// first message keeps it and duplicates get empty one
+		{"messages.client_id duplicates", "UPDATE messages AS m SET client_id = '' " +
+			"FROM (SELECT user_id, client_id, MIN(id) AS id FROM messages WHERE client_id <> '' " +
+			"GROUP BY user_id, client_id HAVING COUNT(*) > 1) AS d " +
+			"WHERE m.user_id = d.user_id AND m.client_id = d.client_id AND m.id <> d.id"},
+	}
+
+	for _, fix := range fixes {
+		result := db.Exec(fix.sql)
+		if result.Error != nil {
+			slog.Error("failed to fix data", "fix", fix.name, "error", result.Error)
+			return result.Error
+		}
+		slog.Info("successfully fixed data", "fix", fix.name, "rows", result.RowsAffected)
+	}
+
+	// indexes which can't be described by gorm tags
+	indexes := []struct {
+		name string
+		sql  string
+	}{
+		// pagination of chat history by id
+		{"idx_messages_chat_id_id", "CREATE INDEX IF NOT EXISTS idx_messages_chat_id_id ON messages (chat_id, id)"},
+		// retried send with same client id creates message once
+		{"idx_messages_user_id_client_id", "CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_user_id_client_id ON messages (user_id, client_id) WHERE client_id <> ''"},
+		// pagination of thread replies by id
+		{"idx_messages_reply_to_id_id", "CREATE INDEX IF NOT EXISTS idx_messages_reply_to_id_id ON messages (reply_to_id, id) WHERE reply_to_id IS NOT NULL"},
+		// scheduler looks only for pending messages by time
+		{"idx_scheduled_messages_due", "CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages (send_at) WHERE status = 'pending' AND deleted_at IS NULL"},
+		// scheduler claims again messages of replica which died while sending
+		{"idx_scheduled_messages_claimed", "CREATE INDEX IF NOT EXISTS idx_scheduled_messages_claimed ON scheduled_messages (claimed_at) WHERE status = 'sending' AND deleted_at IS NULL"},
+		// reaper looks only for messages of chats with ttl
+		{"idx_messages_expires_at", "CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages (expires_at) WHERE expires_at IS NOT NULL"},
+		// full-text search of messages
+		{"idx_messages_content_tsv", "CREATE INDEX IF NOT EXISTS idx_messages_content_tsv ON messages USING GIN (content_tsv)"},
+	}
+
+	for _, index := range indexes {
+		if err := db.Exec(index.sql).Error; err != nil {
+			slog.Error("failed to create index", "index", index.name, "error", err)
+			return err
+		}
+		slog.Info("successfully created index", "index", index.name)
+	}
+
 	slog.Info("database migration completed successfully")
 	return nil
 }
@@ -3,11 +3,17 @@ package msgrepo
 import (
 	"context"
 	"errors"
+	"html"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/response"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MessageRepository struct {
@@ -29,6 +35,39 @@ func (r *MessageRepository) CreateMessage(ctx context.Context, message *entity.M
 	return r.db.WithContext(ctx).Create(message).Error
 }
 
+// message with same not empty client id of user is created once,
+// returns false and fills message with existing one on retry
+func (r *MessageRepository) CreateMessageOnce(ctx context.Context, message *entity.Message) (bool, error) {
+	slog.Debug("creating message once", "chat_id", message.ChatID, "user_id", message.UserID, "client_id", message.ClientID)
+	now := time.Now()
+	message.CreatedAt = now
+	message.UpdatedAt = now
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "client_id <> ''"}}},
+			DoNothing:   true,
+		}).
+		Create(message)
+	if result.Error != nil {
+		slog.Error("error create message", "chat_id", message.ChatID, "user_id", message.UserID, "err", result.Error)
+		return false, errors.New("failed create message")
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND client_id = ?", message.UserID, message.ClientID).
+		First(message).Error
+	if err != nil {
+		slog.Error("error get message by client_id", "user_id", message.UserID, "client_id", message.ClientID, "err", err)
+		return false, errors.New("failed get message")
+	}
+	return false, nil
+}
+
 func (r *MessageRepository) UpdateMessageStatus(ctx context.Context, messageID uint, status entity.MessageStatus) error {
 	slog.Debug("update status message", "message_id", messageID, "status", status)
 	err := r.db.WithContext(ctx).Model(&entity.Message{}).
@@ -55,3 +94,638 @@ func (r *MessageRepository) GetMessageByID(ctx context.Context, id uint) (*entit
 	}
 	return &message, err
 }
+
+// save previous content to message_edits and update message
+func (r *MessageRepository) EditMessage(ctx context.Context, message *entity.Message, content string, entities []entity.MessageEntity, editorID uint) error {
+	slog.Debug("edit message", "message_id", message.ID, "editor_id", editorID)
+	now := time.Now()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		edit := entity.MessageEdit{
+			MessageID: message.ID,
+			EditorID:  editorID,
+			Content:   message.Content,
+			Entities:  message.Entities,
+		}
+		if err := tx.Create(&edit).Error; err != nil {
+			return err
+		}
+		// struct update goes through json serializer of entities
+		return tx.Model(&entity.Message{}).
+			Where("id = ?", message.ID).
+			Select("content", "entities", "edited_at", "updated_at").
+			Updates(&entity.Message{
+				Content:  content,
+				Entities: entities,
+				EditedAt: &now,
+				Model:    gorm.Model{UpdatedAt: now},
+			}).Error
+	})
+	if err != nil {
+		slog.Error("error edit message", "message_id", message.ID, "err", err)
+		return errors.New("failed edit message")
+	}
+
+	message.Content = content
+	message.Entities = entities
+	message.EditedAt = &now
+	message.UpdatedAt = now
+	return nil
+}
+
+// message deleted for everyone, stays in table as soft deleted
+func (r *MessageRepository) DeleteMessage(ctx context.Context, message *entity.Message, deletedBy uint) error {
+	slog.Debug("delete message", "message_id", message.ID, "deleted_by", deletedBy)
+	now := time.Now()
+
+	err := r.db.WithContext(ctx).Model(&entity.Message{}).
+		Where("id = ?", message.ID).
+		Updates(map[string]interface{}{
+			"deleted_by": deletedBy,
+			"deleted_at": now,
+		}).Error
+	if err != nil {
+		slog.Error("error delete message", "message_id", message.ID, "err", err)
+		return errors.New("failed delete message")
+	}
+
+	message.DeletedBy = &deletedBy
+	message.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	return nil
+}
+
+// messages of other users in chat up to message become read
+func (r *MessageRepository) MarkMessagesRead(ctx context.Context, chatID, readerID, upToID uint) error {
+	slog.Debug("mark messages read", "chat_id", chatID, "reader_id", readerID, "up_to_id", upToID)
+	err := r.db.WithContext(ctx).Model(&entity.Message{}).
+		Where("chat_id = ? AND user_id <> ? AND id <= ? AND status <> ?", chatID, readerID, upToID, entity.MessageStatusRead).
+		Updates(map[string]interface{}{
+			"status":     entity.MessageStatusRead,
+			"updated_at": time.Now(),
+		}).Error
+
+	if err != nil {
+		slog.Error("error mark messages read", "chat_id", chatID, "reader_id", readerID, "err", err)
+		return errors.New("failed update message status")
+	}
+	return nil
+}
+
+// returns false if user already put this emoji
+func (r *MessageRepository) AddReaction(ctx context.Context, reaction *entity.MessageReaction) (bool, error) {
+	slog.Debug("add reaction", "message_id", reaction.MessageID, "user_id", reaction.UserID, "emoji", reaction.Emoji)
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(reaction)
+	if result.Error != nil {
+		slog.Error("error add reaction", "message_id", reaction.MessageID, "user_id", reaction.UserID, "err", result.Error)
+		return false, errors.New("failed add reaction")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// returns false if user had not this emoji on message
+func (r *MessageRepository) RemoveReaction(ctx context.Context, messageID, userID uint, emoji string) (bool, error) {
+	slog.Debug("remove reaction", "message_id", messageID, "user_id", userID, "emoji", emoji)
+	result := r.db.WithContext(ctx).
+		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&entity.MessageReaction{})
+	if result.Error != nil {
+		slog.Error("error remove reaction", "message_id", messageID, "user_id", userID, "err", result.Error)
+		return false, errors.New("failed remove reaction")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *MessageRepository) CountReaction(ctx context.Context, messageID uint, emoji string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&entity.MessageReaction{}).
+		Where("message_id = ? AND emoji = ?", messageID, emoji).
+		Count(&count).Error
+	if err != nil {
+		slog.Error("error count reaction", "message_id", messageID, "emoji", emoji, "err", err)
+		return 0, errors.New("failed count reaction")
+	}
+	return count, nil
+}
+
+// reactions of messages grouped by emoji in order of first use, with flag for user
+func (r *MessageRepository) GetReactions(ctx context.Context, messageIDs []uint, userID uint) (map[uint][]*entity.ReactionCount, error) {
+	result := make(map[uint][]*entity.ReactionCount)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		MessageID   uint
+		Emoji       string
+		Count       int64
+		ReactedByMe bool
+	}
+	err := r.db.WithContext(ctx).Model(&entity.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted_by_me", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("MIN(created_at)").
+		Scan(&rows).Error
+	if err != nil {
+		slog.Error("error get reactions", "message_ids", messageIDs, "err", err)
+		return nil, errors.New("failed get reactions")
+	}
+
+	for _, row := range rows {
+		result[row.MessageID] = append(result[row.MessageID], &entity.ReactionCount{
+			Emoji:       row.Emoji,
+			Count:       row.Count,
+			ReactedByMe: row.ReactedByMe,
+		})
+	}
+	return result, nil
+}
+
+// returns page of direct replies to message and cursor for next page
+func (r *MessageRepository) GetThread(ctx context.Context, parentID uint, page request.MessagesPage) ([]*entity.Message, *uint, error) {
+	slog.Debug("get thread", "parent_id", parentID, "before_id", page.BeforeID, "after_id", page.AfterID, "limit", page.Limit)
+	var messages []*entity.Message
+
+	query := r.db.WithContext(ctx).Where("reply_to_id = ?", parentID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+
+	// thread is read from its beginning by default
+	forward := page.Forward(true)
+	if page.BeforeID != 0 {
+		query = query.Where("id < ?", page.BeforeID)
+	}
+	if page.AfterID != 0 {
+		query = query.Where("id > ?", page.AfterID)
+	}
+	if forward {
+		query = query.Order("id ASC")
+	} else {
+		query = query.Order("id DESC")
+	}
+
+	// one extra message shows that next page exists
+	err := query.Limit(page.Limit + 1).
+		Find(&messages).Error
+	if err != nil {
+		slog.Error("error failed get thread", "parent_id", parentID, "err", err)
+		return nil, nil, errors.New("failed get thread")
+	}
+
+	var next *uint
+	if len(messages) > page.Limit {
+		messages = messages[:page.Limit]
+		next = &messages[len(messages)-1].ID
+	}
+	if !forward {
+		slices.Reverse(messages)
+	}
+
+	return messages, next, nil
+}
+
+// count of not deleted and not expired replies for every message
+func (r *MessageRepository) GetReplyCounts(ctx context.Context, messageIDs []uint) (map[uint]int64, error) {
+	result := make(map[uint]int64)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		ReplyToID uint
+		Count     int64
+	}
+	err := r.db.WithContext(ctx).Model(&entity.Message{}).
+		Select("reply_to_id, COUNT(*) AS count").
+		Where("reply_to_id IN ?", messageIDs).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Group("reply_to_id").
+		Scan(&rows).Error
+	if err != nil {
+		slog.Error("error get reply counts", "message_ids", messageIDs, "err", err)
+		return nil, errors.New("failed get reply counts")
+	}
+
+	for _, row := range rows {
+		result[row.ReplyToID] = row.Count
+	}
+	return result, nil
+}
+
+// messages by ids including deleted ones, used for previews of replies
+func (r *MessageRepository) GetMessagesByIDs(ctx context.Context, messageIDs []uint) (map[uint]*entity.Message, error) {
+	result := make(map[uint]*entity.Message)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	var messages []*entity.Message
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("id IN ?", messageIDs).
+		Find(&messages).Error
+	if err != nil {
+		slog.Error("error get messages by ids", "message_ids", messageIDs, "err", err)
+		return nil, errors.New("failed get messages")
+	}
+
+	for _, message := range messages {
+		result[message.ID] = message
+	}
+	return result, nil
+}
+
+// control characters mark matches in ts_headline, they are removed from content
+// before highlighting, so snippet can be html-escaped and markers replaced after
+const (
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+var snippetOptions = "StartSel=" + snippetStart + ", StopSel=" + snippetStop + ", MaxWords=30, MinWords=10, MaxFragments=2"
+
+// content is user input, only <b></b> around matches is markup
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, snippetStart, "<b>")
+	return strings.ReplaceAll(snippet, snippetStop, "</b>")
+}
+
+// ranked search in chats where user is participant, uses content_tsv created by migrate
+func (r *MessageRepository) SearchMessages(ctx context.Context, userID uint, req request.SearchMessages) ([]*response.MessageSearchResult, error) {
+	slog.Debug("search messages", "user_id", userID, "chat_id", req.ChatID, "limit", req.Limit, "offset", req.Offset)
+
+	var rows []struct {
+		entity.Message
+		Rank    float64
+		Snippet string
+	}
+	query := r.db.WithContext(ctx).Table("messages AS m").
+		Select("m.*, ts_rank(m.content_tsv, q) AS rank, "+
+			"ts_headline('simple', translate(m.content, ?, ''), q, ?) AS snippet", snippetStart+snippetStop, snippetOptions).
+		Joins("CROSS JOIN websearch_to_tsquery('simple', ?) AS q", req.Query).
+		Joins("JOIN chat_participants AS p ON p.chat_id = m.chat_id AND p.user_id = ? AND p.deleted_at IS NULL", userID).
+		Where("m.deleted_at IS NULL AND m.content_tsv @@ q").
+		Where("m.expires_at IS NULL OR m.expires_at > ?", time.Now())
+	if req.ChatID != 0 {
+		query = query.Where("m.chat_id = ?", req.ChatID)
+	}
+
+	err := query.Order("rank DESC, m.id DESC").
+		Limit(req.Limit).
+		Offset(req.Offset).
+		Scan(&rows).Error
+	if err != nil {
+		slog.Error("error search messages", "user_id", userID, "chat_id", req.ChatID, "err", err)
+		return nil, errors.New("failed search messages")
+	}
+
+	results := make([]*response.MessageSearchResult, 0, len(rows))
+	for i := range rows {
+		results = append(results, &response.MessageSearchResult{
+			Message: &rows[i].Message,
+			Rank:    rows[i].Rank,
+			Snippet: highlightSnippet(rows[i].Snippet),
+		})
+	}
+	return results, nil
+}
+
+func (r *MessageRepository) CreateScheduledMessage(ctx context.Context, message *entity.ScheduledMessage) error {
+	slog.Debug("create scheduled message", "chat_id", message.ChatID, "user_id", message.UserID, "send_at", message.SendAt)
+	err := r.db.WithContext(ctx).Create(message).Error
+	if err != nil {
+		slog.Error("error create scheduled message", "chat_id", message.ChatID, "user_id", message.UserID, "err", err)
+		return errors.New("failed create scheduled message")
+	}
+	return nil
+}
+
+// pending and sending messages of user, nearest first
+func (r *MessageRepository) GetScheduledMessages(ctx context.Context, userID uint) ([]*entity.ScheduledMessage, error) {
+	slog.Debug("get scheduled messages", "user_id", userID)
+	var messages []*entity.ScheduledMessage
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status IN ?", userID, []entity.ScheduledStatus{entity.ScheduledStatusPending, entity.ScheduledStatusSending}).
+		Order("send_at ASC").
+		Find(&messages).Error
+	if err != nil {
+		slog.Error("error get scheduled messages", "user_id", userID, "err", err)
+		return nil, errors.New("failed get scheduled messages")
+	}
+	return messages, nil
+}
+
+// returns false if there is no pending message of user with this id
+func (r *MessageRepository) CancelScheduledMessage(ctx context.Context, id, userID uint) (bool, error) {
+	slog.Debug("cancel scheduled message", "id", id, "user_id", userID)
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND status = ?", id, userID, entity.ScheduledStatusPending).
+		Delete(&entity.ScheduledMessage{})
+	if result.Error != nil {
+		slog.Error("error cancel scheduled message", "id", id, "user_id", userID, "err", result.Error)
+		return false, errors.New("failed cancel scheduled message")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// marks due messages as sending, SKIP LOCKED gives every message to one replica only,
+// messages left in sending since claimedBefore are claimed again
+func (r *MessageRepository) ClaimDueScheduled(ctx context.Context, limit int, claimedBefore time.Time) ([]*entity.ScheduledMessage, error) {
+	var due []*entity.ScheduledMessage
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND send_at <= ?) OR (status = ? AND claimed_at <= ?)",
+				entity.ScheduledStatusPending, now, entity.ScheduledStatusSending, claimedBefore).
+			Order("send_at ASC").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(due))
+		for _, message := range due {
+			ids = append(ids, message.ID)
+			message.Status = entity.ScheduledStatusSending
+			message.ClaimedAt = &now
+			message.Attempts++
+		}
+		return tx.Model(&entity.ScheduledMessage{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":     entity.ScheduledStatusSending,
+				"claimed_at": now,
+				"attempts":   gorm.Expr("attempts + 1"),
+			}).Error
+	})
+	if err != nil {
+		slog.Error("error claim scheduled messages", "err", err)
+		return nil, errors.New("failed claim scheduled messages")
+	}
+	return due, nil
+}
+
+// result of send of claimed message, failed send returns it to pending
+// until attempts are over
+func (r *MessageRepository) FinishScheduled(ctx context.Context, message *entity.ScheduledMessage, sendErr error, maxAttempts int) error {
+	updates := map[string]interface{}{"status": entity.ScheduledStatusSent, "error": nil}
+	if sendErr != nil {
+		updates["status"] = entity.ScheduledStatusPending
+		updates["error"] = sendErr.Error()
+		if message.Attempts >= maxAttempts {
+			updates["status"] = entity.ScheduledStatusFailed
+		}
+	}
+
+	err := r.db.WithContext(ctx).Model(&entity.ScheduledMessage{}).
+		Where("id = ? AND status = ?", message.ID, entity.ScheduledStatusSending).
+		Updates(updates).Error
+	if err != nil {
+		slog.Error("error finish scheduled message", "id", message.ID, "err", err)
+		return errors.New("failed finish scheduled message")
+	}
+	message.Status = updates["status"].(entity.ScheduledStatus)
+	return nil
+}
+
+// receipts for own messages of recipient are skipped,
+// message becomes delivered once first recipient got it
+func (r *MessageRepository) MarkDelivered(ctx context.Context, receipts []entity.MessageReceipt) error {
+	messageIDs := make([]uint, 0, len(receipts))
+	for _, receipt := range receipts {
+		messageIDs = append(messageIDs, receipt.MessageID)
+	}
+
+	var authors []struct {
+		ID     uint
+		UserID uint
+	}
+	err := r.db.WithContext(ctx).Model(&entity.Message{}).
+		Select("id, user_id").
+		Where("id IN ?", messageIDs).
+		Scan(&authors).Error
+	if err != nil {
+		slog.Error("error get authors of messages", "message_ids", messageIDs, "err", err)
+		return errors.New("failed get messages")
+	}
+	authorOf := make(map[uint]uint, len(authors))
+	for _, author := range authors {
+		authorOf[author.ID] = author.UserID
+	}
+
+	toSave := make([]entity.MessageReceipt, 0, len(receipts))
+	delivered := make([]uint, 0, len(receipts))
+	for _, receipt := range receipts {
+		author, ok := authorOf[receipt.MessageID]
+		if !ok || author == receipt.UserID {
+			continue
+		}
+		toSave = append(toSave, receipt)
+		delivered = append(delivered, receipt.MessageID)
+	}
+	if len(toSave) == 0 {
+		return nil
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+			DoNothing: true,
+		}).Create(&toSave).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&entity.Message{}).
+			Where("id IN ? AND status = ?", delivered, entity.MessageStatusSent).
+			Updates(map[string]interface{}{
+				"status":     entity.MessageStatusDelivered,
+				"updated_at": time.Now(),
+			}).Error
+	})
+	if err != nil {
+		slog.Error("error save delivery receipts", "count", len(toSave), "err", err)
+		return errors.New("failed save delivery receipts")
+	}
+	return nil
+}
+
+// messages of others in (afterID, upToID] become read by reader
+func (r *MessageRepository) MarkReceiptsRead(ctx context.Context, chatID, readerID, afterID, upToID uint) error {
+	slog.Debug("mark receipts read", "chat_id", chatID, "reader_id", readerID, "after_id", afterID, "up_to_id", upToID)
+	err := r.db.WithContext(ctx).Exec(`
+		INSERT INTO message_receipts (message_id, user_id, delivered_at, read_at, created_at)
+		SELECT id, ?, NOW(), NOW(), NOW() FROM messages
+		WHERE chat_id = ? AND id > ? AND id <= ? AND user_id <> ? AND deleted_at IS NULL
+		ON CONFLICT (message_id, user_id) DO UPDATE SET
+			delivered_at = COALESCE(message_receipts.delivered_at, EXCLUDED.delivered_at),
+			read_at = COALESCE(message_receipts.read_at, EXCLUDED.read_at)`,
+		readerID, chatID, afterID, upToID, readerID).Error
+	if err != nil {
+		slog.Error("error mark receipts read", "chat_id", chatID, "reader_id", readerID, "err", err)
+		return errors.New("failed mark receipts read")
+	}
+	return nil
+}
+
+func (r *MessageRepository) GetReceiptCounts(ctx context.Context, messageIDs []uint) (map[uint]*entity.ReceiptCounts, error) {
+	result := make(map[uint]*entity.ReceiptCounts)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		MessageID uint
+		Delivered int64
+		Read      int64
+	}
+	err := r.db.WithContext(ctx).Model(&entity.MessageReceipt{}).
+		Select("message_id, COUNT(delivered_at) AS delivered, COUNT(read_at) AS read").
+		Where("message_id IN ?", messageIDs).
+		Group("message_id").
+		Scan(&rows).Error
+	if err != nil {
+		slog.Error("error get receipt counts", "message_ids", messageIDs, "err", err)
+		return nil, errors.New("failed get receipt counts")
+	}
+
+	for _, row := range rows {
+		result[row.MessageID] = &entity.ReceiptCounts{Delivered: row.Delivered, Read: row.Read}
+	}
+	return result, nil
+}
+
+// consumer marks message once it was broadcast
+func (r *MessageRepository) MarkBroadcast(ctx context.Context, messageID uint) error {
+	err := r.db.WithContext(ctx).Model(&entity.Message{}).
+		Where("id = ?", messageID).
+		Update("broadcast_at", time.Now()).Error
+	if err != nil {
+		slog.Error("error mark message broadcast", "message_id", messageID, "err", err)
+		return errors.New("failed mark message broadcast")
+	}
+	return nil
+}
+
+// hard deletes expired messages with everything attached to them,
+// SKIP LOCKED lets every replica run reaper. returns chatID -> removed message ids
+func (r *MessageRepository) DeleteExpiredMessages(ctx context.Context, limit int) (map[uint][]uint, error) {
+	removed := make(map[uint][]uint)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var expired []struct {
+			ID     uint
+			ChatID uint
+		}
+		err := tx.Unscoped().Model(&entity.Message{}).
+			Select("id, chat_id").
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("expires_at <= ?", time.Now()).
+			Order("expires_at ASC").
+			Limit(limit).
+			Scan(&expired).Error
+		if err != nil || len(expired) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(expired))
+		for _, message := range expired {
+			ids = append(ids, message.ID)
+		}
+
+		for _, model := range []interface{}{
+			&entity.MessageReaction{},
+			&entity.MessageReceipt{},
+			&entity.MessageEdit{},
+			&entity.ChatPin{},
+			&entity.MessageMention{},
+		} {
+			if err := tx.Unscoped().Where("message_id IN ?", ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// replies stay, only link to removed message is lost
+		err = tx.Unscoped().Model(&entity.Message{}).
+			Where("reply_to_id IN ?", ids).
+			Update("reply_to_id", nil).Error
+		if err != nil {
+			return err
+		}
+		// forwarded copies stay too, source chat and author are still shown
+		err = tx.Unscoped().Model(&entity.Message{}).
+			Where("forwarded_from_message_id IN ?", ids).
+			Update("forwarded_from_message_id", nil).Error
+		if err != nil {
+			return err
+		}
+
+		// read pointer moves back to nearest kept message, so unread counts stay the same
+		err = tx.Exec(`
+			UPDATE chat_participants AS p SET last_read_message_id = (
+				SELECT MAX(m.id) FROM messages AS m
+				WHERE m.chat_id = p.chat_id AND m.id <= p.last_read_message_id AND m.id NOT IN ?
+			)
+			WHERE p.last_read_message_id IN ?`, ids, ids).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(&entity.Message{}).Error; err != nil {
+			return err
+		}
+
+		for _, message := range expired {
+			removed[message.ChatID] = append(removed[message.ChatID], message.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("error delete expired messages", "err", err)
+		return nil, errors.New("failed delete expired messages")
+	}
+	return removed, nil
+}
+
+func (r *MessageRepository) CreateMentions(ctx context.Context, mentions []entity.MessageMention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	slog.Debug("create mentions", "message_id", mentions[0].MessageID, "count", len(mentions))
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+			DoNothing: true,
+		}).
+		Create(&mentions).Error
+	if err != nil {
+		slog.Error("error create mentions", "message_id", mentions[0].MessageID, "err", err)
+		return errors.New("failed create mentions")
+	}
+	return nil
+}
+
+// messages mentioning user after his read pointer, newest first
+func (r *MessageRepository) GetUnreadMentions(ctx context.Context, userID, chatID uint, limit int) ([]*entity.Message, error) {
+	slog.Debug("get unread mentions", "user_id", userID, "chat_id", chatID)
+	var messages []*entity.Message
+
+	query := r.db.WithContext(ctx).
+		Joins("JOIN message_mentions AS mm ON mm.message_id = messages.id AND mm.user_id = ?", userID).
+		Joins("JOIN chat_participants AS p ON p.chat_id = mm.chat_id AND p.user_id = mm.user_id AND p.deleted_at IS NULL").
+		Where("messages.id > COALESCE(p.last_read_message_id, 0)").
+		Where("messages.expires_at IS NULL OR messages.expires_at > ?", time.Now())
+	if chatID != 0 {
+		query = query.Where("mm.chat_id = ?", chatID)
+	}
+
+	err := query.Order("messages.id DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		slog.Error("error get unread mentions", "user_id", userID, "err", err)
+		return nil, errors.New("failed get mentions")
+	}
+	return messages, nil
+}
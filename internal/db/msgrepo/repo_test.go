@@ -0,0 +1,827 @@
+package msgrepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sibhellyx/Messenger/internal/db/migrate"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// repository on database from TEST_DATABASE_DSN, test is skipped without it.
+// tests create own users and chats, so rows of other runs don't interfere
+func newTestRepository(t *testing.T) (*MessageRepository, *gorm.DB) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  dsn,
+		PreferSimpleProtocol: true,
+	}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrateOnce.Do(func() { migrateErr = migrate.Migrate(db) })
+	if migrateErr != nil {
+		t.Fatal(migrateErr)
+	}
+	return NewMessageRepository(db), db
+}
+
+// chat with new users as participants
+func newTestChat(t *testing.T, db *gorm.DB, users int) (*entity.Chat, []uint) {
+	t.Helper()
+	ids := make([]uint, 0, users)
+	for i := 0; i < users; i++ {
+		user := entity.User{Name: "test", Surname: "test", Tgname: "test_" + uuid.NewString(), Password: "test"}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	chat := &entity.Chat{Name: "test", Type: entity.ChatTypeGroup, CreatedBy: ids[0]}
+	if err := db.Create(chat).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if err := db.Create(&entity.ChatParticipant{ChatID: chat.ID, UserID: id, Role: entity.RoleMember}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chat, ids
+}
+
+func newTestMessage(t *testing.T, r *MessageRepository, chatID, userID uint, content string) *entity.Message {
+	t.Helper()
+	message := &entity.Message{ChatID: chatID, UserID: userID, Type: entity.MessageTypeText, Content: content, Status: entity.MessageStatusSent}
+	if err := r.CreateMessage(context.Background(), message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{name: "plain text", snippet: "hello world", want: "hello world"},
+		{name: "match", snippet: "say \x02hello\x03 world", want: "say <b>hello</b> world"},
+		{name: "several matches", snippet: "\x02a\x03 and \x02b\x03", want: "<b>a</b> and <b>b</b>"},
+		{
+			name:    "markup of content is escaped",
+			snippet: "<script>alert('x')</script> \x02hi\x03 & <b>bye</b>",
+			want:    "&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt; <b>hi</b> &amp; &lt;b&gt;bye&lt;/b&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.snippet); got != tt.want {
+				t.Errorf("highlightSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+
+	// random word keeps search inside messages of this run
+	word := "w" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	chat, users := newTestChat(t, db, 2)
+	other, _ := newTestChat(t, db, 1)
+
+	found := newTestMessage(t, r, chat.ID, users[1], "<i>"+word+"</i> \x02marker\x03")
+	newTestMessage(t, r, other.ID, users[0], word+" in chat of other users")
+	expired := newTestMessage(t, r, chat.ID, users[1], word+" expired")
+	if err := db.Model(expired).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	deleted := newTestMessage(t, r, chat.ID, users[1], word+" deleted")
+	if err := r.DeleteMessage(ctx, deleted, users[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := r.SearchMessages(ctx, users[0], request.SearchMessages{Query: word, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Message.ID != found.ID {
+		t.Fatalf("results = %d, want only message %d", len(results), found.ID)
+	}
+
+	snippet := results[0].Snippet
+	if !strings.Contains(snippet, "&lt;i&gt;<b>"+word+"</b>&lt;/i&gt;") {
+		t.Errorf("snippet %q has no escaped highlighted match", snippet)
+	}
+	if strings.ContainsAny(snippet, "\x02\x03") || strings.Count(snippet, "<b>") != 1 {
+		t.Errorf("snippet %q highlights markers of content", snippet)
+	}
+}
+
+func TestEditMessageKeepsHistory(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+	chat, users := newTestChat(t, db, 1)
+
+	message := newTestMessage(t, r, chat.ID, users[0], "first")
+	bold := []entity.MessageEntity{{Type: entity.EntityBold, Offset: 0, Length: 6}}
+	if err := r.EditMessage(ctx, message, "second", bold, users[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.EditMessage(ctx, message, "third", nil, users[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := r.GetMessageByID(ctx, message.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Content != "third" || stored.EditedAt == nil || len(stored.Entities) != 0 {
+		t.Errorf("message = %q, edited at %v, entities %v, want third edited without entities",
+			stored.Content, stored.EditedAt, stored.Entities)
+	}
+
+	var edits []entity.MessageEdit
+	if err := db.Where("message_id = ?", message.ID).Order("id").Find(&edits).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 2 {
+		t.Fatalf("edits = %d, want 2", len(edits))
+	}
+	if edits[0].Content != "first" || len(edits[0].Entities) != 0 {
+		t.Errorf("first edit = %q %v, want first without entities", edits[0].Content, edits[0].Entities)
+	}
+	if edits[1].Content != "second" || len(edits[1].Entities) != 1 || edits[1].Entities[0].Type != entity.EntityBold {
+		t.Errorf("second edit = %q %v, want second in bold", edits[1].Content, edits[1].Entities)
+	}
+	if edits[1].EditorID != users[0] {
+		t.Errorf("editor = %d, want %d", edits[1].EditorID, users[0])
+	}
+}
+
+func TestDeletedMessageIsHidden(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+	chat, users := newTestChat(t, db, 2)
+
+	message := newTestMessage(t, r, chat.ID, users[0], "hello")
+	if err := r.DeleteMessage(ctx, message, users[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.GetMessageByID(ctx, message.ID); err == nil {
+		t.Error("deleted message is returned")
+	}
+	var stored entity.Message
+	if err := db.Unscoped().First(&stored, message.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.DeletedBy == nil || *stored.DeletedBy != users[1] {
+		t.Errorf("deleted by = %v, want %d", stored.DeletedBy, users[1])
+	}
+}
+
+func TestReactions(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+	chat, users := newTestChat(t, db, 2)
+	me, other := users[0], users[1]
+	message := newTestMessage(t, r, chat.ID, other, "hello")
+
+	add := func(userID uint, emoji string) bool {
+		t.Helper()
+		added, err := r.AddReaction(ctx, &entity.MessageReaction{MessageID: message.ID, UserID: userID, Emoji: emoji})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return added
+	}
+
+	steps := []struct {
+		name   string
+		got    func() bool
+		want   bool
+		counts map[string]int64
+	}{
+		{name: "first reaction", got: func() bool { return add(other, "👍") }, want: true, counts: map[string]int64{"👍": 1}},
+		{name: "same emoji again", got: func() bool { return add(other, "👍") }, want: false, counts: map[string]int64{"👍": 1}},
+		{name: "same emoji of other user", got: func() bool { return add(me, "👍") }, want: true, counts: map[string]int64{"👍": 2}},
+		{name: "other emoji of same user", got: func() bool { return add(me, "🔥") }, want: true, counts: map[string]int64{"👍": 2, "🔥": 1}},
+		{
+			name: "remove reaction",
+			got: func() bool {
+				removed, err := r.RemoveReaction(ctx, message.ID, me, "👍")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return removed
+			},
+			want:   true,
+			counts: map[string]int64{"👍": 1, "🔥": 1},
+		},
+		{
+			name: "remove missing reaction",
+			got: func() bool {
+				removed, err := r.RemoveReaction(ctx, message.ID, me, "👍")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return removed
+			},
+			want:   false,
+			counts: map[string]int64{"👍": 1, "🔥": 1},
+		},
+	}
+
+	// steps run in order on same message
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if got := step.got(); got != step.want {
+				t.Errorf("changed = %v, want %v", got, step.want)
+			}
+			for emoji, want := range step.counts {
+				if count, err := r.CountReaction(ctx, message.ID, emoji); err != nil || count != want {
+					t.Errorf("count of %s = %d, %v, want %d", emoji, count, err, want)
+				}
+			}
+		})
+	}
+
+	reactions, err := r.GetReactions(ctx, []uint{message.ID}, me)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reactions[message.ID]
+	if len(got) != 2 {
+		t.Fatalf("reactions = %d, want 2", len(got))
+	}
+	// in order of first use
+	want := []entity.ReactionCount{{Emoji: "👍", Count: 1, ReactedByMe: false}, {Emoji: "🔥", Count: 1, ReactedByMe: true}}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("reaction %d = %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
+
+func TestThreadOfMessage(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+	chat, users := newTestChat(t, db, 1)
+
+	parent := newTestMessage(t, r, chat.ID, users[0], "parent")
+	lonely := newTestMessage(t, r, chat.ID, users[0], "without replies")
+	var replies []uint
+	for i := 0; i < 3; i++ {
+		reply := &entity.Message{ChatID: chat.ID, UserID: users[0], Type: entity.MessageTypeText, Content: "reply", Status: entity.MessageStatusSent, ReplyToID: &parent.ID}
+		if err := r.CreateMessage(ctx, reply); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply.ID)
+	}
+	// reply to reply is not in thread of parent
+	nested := &entity.Message{ChatID: chat.ID, UserID: users[0], Type: entity.MessageTypeText, Content: "nested", Status: entity.MessageStatusSent, ReplyToID: &replies[0]}
+	if err := r.CreateMessage(ctx, nested); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		page     request.MessagesPage
+		want     []uint
+		wantNext bool
+	}{
+		{name: "from beginning", page: request.MessagesPage{Limit: 2}, want: replies[:2], wantNext: true},
+		{name: "after cursor", page: request.MessagesPage{AfterID: replies[1], Limit: 2}, want: replies[2:]},
+		{name: "before cursor", page: request.MessagesPage{BeforeID: replies[2], Limit: 1}, want: replies[1:2], wantNext: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, next, err := r.GetThread(ctx, parent.ID, tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for _, message := range page {
+				got = append(got, message.ID)
+			}
+			if !slices.Equal(got, tt.want) || (next != nil) != tt.wantNext {
+				t.Errorf("thread = %v, next %v, want %v, next %v", got, next, tt.want, tt.wantNext)
+			}
+		})
+	}
+
+	counts, err := r.GetReplyCounts(ctx, []uint{parent.ID, replies[0], lonely.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[parent.ID] != 3 || counts[replies[0]] != 1 || counts[lonely.ID] != 0 {
+		t.Errorf("reply counts = %v, want 3 for parent and 1 for first reply", counts)
+	}
+}
+
+func newTestScheduled(t *testing.T, db *gorm.DB, chatID, userID uint, sendAt time.Time, status entity.ScheduledStatus, claimedAt *time.Time, attempts int) *entity.ScheduledMessage {
+	t.Helper()
+	message := &entity.ScheduledMessage{ChatID: chatID, UserID: userID, Type: entity.MessageTypeText, Content: "later",
+		SendAt: sendAt, Status: status, ClaimedAt: claimedAt, Attempts: attempts}
+	if err := db.Create(message).Error; err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+// claims all due messages of database and keeps ones of chat
+func claimOfChat(t *testing.T, r *MessageRepository, chatID uint, claimedBefore time.Time) map[uint]*entity.ScheduledMessage {
+	t.Helper()
+	claimed, err := r.ClaimDueScheduled(context.Background(), 1000, claimedBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[uint]*entity.ScheduledMessage)
+	for _, message := range claimed {
+		if message.ChatID == chatID {
+			result[message.ID] = message
+		}
+	}
+	return result
+}
+
+func TestClaimDueScheduled(t *testing.T) {
+	r, db := newTestRepository(t)
+	chat, users := newTestChat(t, db, 1)
+	now := time.Now()
+	staleClaim, freshClaim := now.Add(-2*time.Minute), now
+
+	due := newTestScheduled(t, db, chat.ID, users[0], now.Add(-time.Second), entity.ScheduledStatusPending, nil, 0)
+	newTestScheduled(t, db, chat.ID, users[0], now.Add(time.Hour), entity.ScheduledStatusPending, nil, 0)
+	stale := newTestScheduled(t, db, chat.ID, users[0], now.Add(-time.Hour), entity.ScheduledStatusSending, &staleClaim, 2)
+	newTestScheduled(t, db, chat.ID, users[0], now.Add(-time.Hour), entity.ScheduledStatusSending, &freshClaim, 1)
+	newTestScheduled(t, db, chat.ID, users[0], now.Add(-time.Hour), entity.ScheduledStatusFailed, nil, 5)
+	newTestScheduled(t, db, chat.ID, users[0], now.Add(-time.Hour), entity.ScheduledStatusSent, nil, 1)
+
+	claimed := claimOfChat(t, r, chat.ID, now.Add(-time.Minute))
+	want := map[uint]int{due.ID: 1, stale.ID: 3} // id -> attempts
+	if len(claimed) != len(want) {
+		t.Fatalf("claimed %d messages, want %d", len(claimed), len(want))
+	}
+	for id, attempts := range want {
+		message, ok := claimed[id]
+		if !ok {
+			t.Errorf("message %d not claimed", id)
+			continue
+		}
+		var stored entity.ScheduledMessage
+		if err := db.First(&stored, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range []*entity.ScheduledMessage{message, &stored} {
+			if m.Status != entity.ScheduledStatusSending || m.ClaimedAt == nil || m.Attempts != attempts {
+				t.Errorf("message %d = %s, claimed at %v, attempts %d, want sending with %d attempts",
+					id, m.Status, m.ClaimedAt, m.Attempts, attempts)
+			}
+		}
+	}
+
+	if again := claimOfChat(t, r, chat.ID, now.Add(-time.Minute)); len(again) != 0 {
+		t.Errorf("claimed messages are claimed again: %v", again)
+	}
+}
+
+func TestClaimDueScheduledOnce(t *testing.T) {
+	r, db := newTestRepository(t)
+	chat, users := newTestChat(t, db, 1)
+	for i := 0; i < 20; i++ {
+		newTestScheduled(t, db, chat.ID, users[0], time.Now().Add(-time.Second), entity.ScheduledStatusPending, nil, 0)
+	}
+
+	// schedulers of several replicas
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[uint]int)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := r.ClaimDueScheduled(context.Background(), 1000, time.Now().Add(-time.Minute))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, message := range claimed {
+				if message.ChatID == chat.ID {
+					seen[message.ID]++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 20 {
+		t.Errorf("claimed %d messages, want 20", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("message %d claimed %d times", id, n)
+		}
+	}
+}
+
+func TestFinishScheduled(t *testing.T) {
+	r, db := newTestRepository(t)
+	chat, users := newTestChat(t, db, 1)
+	claimedAt := time.Now()
+
+	tests := []struct {
+		name      string
+		status    entity.ScheduledStatus
+		attempts  int
+		sendErr   error
+		want      entity.ScheduledStatus
+		wantError bool
+	}{
+		{name: "sent", status: entity.ScheduledStatusSending, attempts: 1, want: entity.ScheduledStatusSent},
+		{name: "failed send is retried", status: entity.ScheduledStatusSending, attempts: 1, sendErr: errors.New("kafka is down"), want: entity.ScheduledStatusPending, wantError: true},
+		{name: "last attempt failed", status: entity.ScheduledStatusSending, attempts: 3, sendErr: errors.New("kafka is down"), want: entity.ScheduledStatusFailed, wantError: true},
+		{name: "sent after failed attempt", status: entity.ScheduledStatusSending, attempts: 2, want: entity.ScheduledStatusSent},
+		{name: "message not claimed is kept", status: entity.ScheduledStatusPending, attempts: 0, want: entity.ScheduledStatusPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := newTestScheduled(t, db, chat.ID, users[0], time.Now(), tt.status, &claimedAt, tt.attempts)
+			if tt.status == entity.ScheduledStatusSending {
+				// last send attempt left error
+				db.Model(message).Update("error", "previous")
+			}
+
+			if err := r.FinishScheduled(context.Background(), message, tt.sendErr, 3); err != nil {
+				t.Fatal(err)
+			}
+
+			var stored entity.ScheduledMessage
+			if err := db.First(&stored, message.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.want {
+				t.Errorf("status = %s, want %s", stored.Status, tt.want)
+			}
+			if tt.wantError && (stored.Error == nil || *stored.Error != tt.sendErr.Error()) {
+				t.Errorf("error = %v, want %q", stored.Error, tt.sendErr)
+			}
+			if tt.want == entity.ScheduledStatusSent && stored.Error != nil {
+				t.Errorf("error of sent message = %q", *stored.Error)
+			}
+		})
+	}
+}
+
+func TestCreateMessageOnce(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+	chat, users := newTestChat(t, db, 2)
+
+	create := func(userID uint, clientID, content string) (*entity.Message, bool) {
+		t.Helper()
+		message := &entity.Message{ChatID: chat.ID, UserID: userID, Type: entity.MessageTypeText, Content: content, Status: entity.MessageStatusSent, ClientID: clientID}
+		created, err := r.CreateMessageOnce(ctx, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return message, created
+	}
+
+	clientID := uuid.NewString()
+	first, created := create(users[0], clientID, "first")
+	if !created || first.ID == 0 {
+		t.Fatalf("first send created = %v, id %d", created, first.ID)
+	}
+
+	tests := []struct {
+		name        string
+		userID      uint
+		clientID    string
+		wantCreated bool
+		wantFirst   bool // message of first send is returned
+	}{
+		{name: "retry returns first message", userID: users[0], clientID: clientID, wantFirst: true},
+		{name: "other client id", userID: users[0], clientID: uuid.NewString(), wantCreated: true},
+		{name: "same client id of other user", userID: users[1], clientID: clientID, wantCreated: true},
+		{name: "empty client id is not unique", userID: users[0], clientID: "", wantCreated: true},
+		{name: "empty client id again", userID: users[0], clientID: "", wantCreated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, created := create(tt.userID, tt.clientID, "retry")
+			if created != tt.wantCreated {
+				t.Errorf("created = %v, want %v", created, tt.wantCreated)
+			}
+			if isFirst := message.ID == first.ID; isFirst != tt.wantFirst {
+				t.Errorf("message %d, first is %d", message.ID, first.ID)
+			}
+			if tt.wantFirst && message.Content != "first" {
+				t.Errorf("content = %q, want content of first send", message.Content)
+			}
+		})
+	}
+}
+
+func TestCreateMessageOnceConcurrently(t *testing.T) {
+	r, db := newTestRepository(t)
+	chat, users := newTestChat(t, db, 1)
+	clientID := uuid.NewString()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ids := make(map[uint]bool)
+	created := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			message := &entity.Message{ChatID: chat.ID, UserID: users[0], Type: entity.MessageTypeText, Content: "hello", Status: entity.MessageStatusSent, ClientID: clientID}
+			ok, err := r.CreateMessageOnce(context.Background(), message)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			ids[message.ID] = true
+			if ok {
+				created++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != 1 || len(ids) != 1 {
+		t.Errorf("created %d messages with ids %v, want one", created, ids)
+	}
+}
+
+func TestReceipts(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+	chat, users := newTestChat(t, db, 3)
+	author, first, second := users[0], users[1], users[2]
+
+	var messages []*entity.Message
+	for i := 0; i < 3; i++ {
+		messages = append(messages, newTestMessage(t, r, chat.ID, author, "hello"))
+	}
+	ids := []uint{messages[0].ID, messages[1].ID, messages[2].ID}
+	now := time.Now()
+	receipt := func(messageID, userID uint) entity.MessageReceipt {
+		return entity.MessageReceipt{MessageID: messageID, UserID: userID, DeliveredAt: &now}
+	}
+
+	steps := []struct {
+		name       string
+		run        func() error
+		want       []entity.ReceiptCounts // of messages
+		wantStatus []entity.MessageStatus
+	}{
+		{
+			name: "delivered to first recipient",
+			run: func() error {
+				return r.MarkDelivered(ctx, []entity.MessageReceipt{receipt(ids[0], first), receipt(ids[1], first), receipt(ids[0], first)})
+			},
+			want:       []entity.ReceiptCounts{{Delivered: 1}, {Delivered: 1}, {}},
+			wantStatus: []entity.MessageStatus{entity.MessageStatusDelivered, entity.MessageStatusDelivered, entity.MessageStatusSent},
+		},
+		{
+			name:       "own messages are not receipts",
+			run:        func() error { return r.MarkDelivered(ctx, []entity.MessageReceipt{receipt(ids[2], author)}) },
+			want:       []entity.ReceiptCounts{{Delivered: 1}, {Delivered: 1}, {}},
+			wantStatus: []entity.MessageStatus{entity.MessageStatusDelivered, entity.MessageStatusDelivered, entity.MessageStatusSent},
+		},
+		{
+			name:       "read by second recipient",
+			run:        func() error { return r.MarkReceiptsRead(ctx, chat.ID, second, 0, ids[1]) },
+			want:       []entity.ReceiptCounts{{Delivered: 2, Read: 1}, {Delivered: 2, Read: 1}, {}},
+			wantStatus: []entity.MessageStatus{entity.MessageStatusDelivered, entity.MessageStatusDelivered, entity.MessageStatusSent},
+		},
+		{
+			name:       "read after delivery keeps receipt",
+			run:        func() error { return r.MarkReceiptsRead(ctx, chat.ID, first, 0, ids[2]) },
+			want:       []entity.ReceiptCounts{{Delivered: 2, Read: 2}, {Delivered: 2, Read: 2}, {Delivered: 1, Read: 1}},
+			wantStatus: []entity.MessageStatus{entity.MessageStatusDelivered, entity.MessageStatusDelivered, entity.MessageStatusSent},
+		},
+		{
+			name:       "delivery after read changes nothing",
+			run:        func() error { return r.MarkDelivered(ctx, []entity.MessageReceipt{receipt(ids[0], second)}) },
+			want:       []entity.ReceiptCounts{{Delivered: 2, Read: 2}, {Delivered: 2, Read: 2}, {Delivered: 1, Read: 1}},
+			wantStatus: []entity.MessageStatus{entity.MessageStatusDelivered, entity.MessageStatusDelivered, entity.MessageStatusSent},
+		},
+	}
+
+	// steps run in order on same messages
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := step.run(); err != nil {
+				t.Fatal(err)
+			}
+
+			counts, err := r.GetReceiptCounts(ctx, ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, id := range ids {
+				got := entity.ReceiptCounts{}
+				if counts[id] != nil {
+					got = *counts[id]
+				}
+				if got != step.want[i] {
+					t.Errorf("receipts of message %d = %+v, want %+v", i, got, step.want[i])
+				}
+
+				stored, err := r.GetMessageByID(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stored.Status != step.wantStatus[i] {
+					t.Errorf("status of message %d = %s, want %s", i, stored.Status, step.wantStatus[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpiredMessages(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+	chat, users := newTestChat(t, db, 2)
+	past := time.Now().Add(-time.Minute)
+
+	reply := func(parentID uint, expiresAt *time.Time) *entity.Message {
+		t.Helper()
+		message := &entity.Message{ChatID: chat.ID, UserID: users[0], Type: entity.MessageTypeText, Content: "reply",
+			Status: entity.MessageStatusSent, ReplyToID: &parentID, ExpiresAt: expiresAt}
+		if err := r.CreateMessage(ctx, message); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+
+	kept := newTestMessage(t, r, chat.ID, users[0], "kept")
+	liveReply := reply(kept.ID, nil)
+	expiredReply := reply(kept.ID, &past)
+	expiredParent := reply(kept.ID, &past)
+	orphan := reply(expiredParent.ID, nil)
+	forwarded := expiredParent.Forward(chat.ID, users[1])
+	if err := r.CreateMessage(ctx, &forwarded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.AddReaction(ctx, &entity.MessageReaction{MessageID: expiredReply.ID, UserID: users[1], Emoji: "👍"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// hidden until reaper removes them
+	thread, _, err := r.GetThread(ctx, kept.ID, request.MessagesPage{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread) != 1 || thread[0].ID != liveReply.ID {
+		t.Errorf("thread has %d messages, want only live reply", len(thread))
+	}
+	counts, err := r.GetReplyCounts(ctx, []uint{kept.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[kept.ID] != 1 {
+		t.Errorf("reply count = %d, want 1", counts[kept.ID])
+	}
+
+	// other runs may leave expired messages, reaper takes them too
+	removed := make(map[uint]bool)
+	for {
+		batch, err := r.DeleteExpiredMessages(ctx, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, id := range batch[chat.ID] {
+			removed[id] = true
+		}
+	}
+	if len(removed) != 2 || !removed[expiredReply.ID] || !removed[expiredParent.ID] {
+		t.Errorf("removed = %v, want expired reply and parent", removed)
+	}
+
+	var left int64
+	db.Unscoped().Model(&entity.Message{}).Where("id IN ?", []uint{expiredReply.ID, expiredParent.ID}).Count(&left)
+	if left != 0 {
+		t.Errorf("%d expired messages left in table", left)
+	}
+	db.Model(&entity.MessageReaction{}).Where("message_id = ?", expiredReply.ID).Count(&left)
+	if left != 0 {
+		t.Errorf("%d reactions of expired message left", left)
+	}
+
+	stored, err := r.GetMessageByID(ctx, orphan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ReplyToID != nil {
+		t.Errorf("reply keeps link to removed message %d", *stored.ReplyToID)
+	}
+	stored, err = r.GetMessageByID(ctx, forwarded.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ForwardedFromMessageID != nil {
+		t.Errorf("forwarded copy keeps link to removed message %d", *stored.ForwardedFromMessageID)
+	}
+	if stored.ForwardedFromChatID == nil || *stored.ForwardedFromChatID != chat.ID {
+		t.Errorf("forwarded copy lost source chat")
+	}
+}
+
+func TestUnreadMentions(t *testing.T) {
+	r, db := newTestRepository(t)
+	ctx := context.Background()
+	chat, users := newTestChat(t, db, 2)
+	other, otherUsers := newTestChat(t, db, 1)
+	author, me := users[0], users[1]
+	db.Create(&entity.ChatParticipant{ChatID: other.ID, UserID: me, Role: entity.RoleMember})
+
+	mention := func(chatID, authorID uint) *entity.Message {
+		t.Helper()
+		message := newTestMessage(t, r, chatID, authorID, "hi")
+		mentions := []entity.MessageMention{{MessageID: message.ID, UserID: me, ChatID: chatID}}
+		// saved twice like on redelivery
+		for i := 0; i < 2; i++ {
+			if err := r.CreateMentions(ctx, mentions); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return message
+	}
+
+	read := mention(chat.ID, author)
+	db.Model(&entity.ChatParticipant{}).Where("chat_id = ? AND user_id = ?", chat.ID, me).Update("last_read_message_id", read.ID)
+	unread := mention(chat.ID, author)
+	newTestMessage(t, r, chat.ID, author, "without mention")
+	expired := mention(chat.ID, author)
+	db.Model(expired).Update("expires_at", time.Now().Add(-time.Minute))
+	deleted := mention(chat.ID, author)
+	db.Delete(deleted)
+	inOther := mention(other.ID, otherUsers[0])
+
+	tests := []struct {
+		name   string
+		userID uint
+		chatID uint
+		want   []uint
+	}{
+		{name: "all chats newest first", userID: me, want: []uint{inOther.ID, unread.ID}},
+		{name: "one chat", userID: me, chatID: chat.ID, want: []uint{unread.ID}},
+		{name: "not mentioned user", userID: author, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := r.GetUnreadMentions(ctx, tt.userID, tt.chatID, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for _, message := range messages {
+				got = append(got, message.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("mentions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -124,6 +124,46 @@ func (r *UserRepository) GetUserById(userId uint) (*entity.User, error) {
 	return &user, nil
 }
 
+// ids from userIDs of users who are participants of any chat of user
+func (r *UserRepository) GetUsersSharingChat(userID uint, userIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(userIDs) == 0 {
+		return ids, nil
+	}
+
+	result := r.db.Table("chat_participants AS p").
+		Joins("JOIN chat_participants AS o ON o.chat_id = p.chat_id AND o.deleted_at IS NULL").
+		Where("p.user_id = ? AND p.deleted_at IS NULL AND o.user_id IN ?", userID, userIDs).
+		Distinct().
+		Pluck("o.user_id", &ids)
+	if result.Error != nil {
+		slog.Error("failed to get users sharing chat", "error", result.Error, "user_id", userID)
+		return nil, errors.New("failed get users")
+	}
+
+	return ids, nil
+}
+
+// participants of chat with given lowercased tgnames, unknown names are skipped
+func (r *UserRepository) GetChatParticipantsByTgnames(chatID uint, tgnames []string) ([]*entity.User, error) {
+	var users []*entity.User
+	if len(tgnames) == 0 {
+		return users, nil
+	}
+
+	result := r.db.Model(&entity.User{}).
+		Select("users.id, users.name, users.surname, users.tgname").
+		Joins("JOIN chat_participants AS p ON p.user_id = users.id AND p.chat_id = ? AND p.deleted_at IS NULL", chatID).
+		Where("LOWER(users.tgname) IN ?", tgnames).
+		Find(&users)
+	if result.Error != nil {
+		slog.Error("failed to get participants by tgnames", "error", result.Error, "chat_id", chatID)
+		return nil, errors.New("failed get users by tgnames")
+	}
+
+	return users, nil
+}
+
 func (r *UserRepository) GetFullInfoAboutUser(userId uint) (*response.UserWithProfile, error) {
 	var profile entity.UserProfile
 	result := r.db.Preload("User").Where("user_id = ?", userId).First(&profile)
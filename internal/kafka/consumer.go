@@ -16,7 +16,7 @@ import (
 )
 
 type MessageProcessor interface {
-	ProcessKafkaMessage(ctx context.Context, message entity.Message) error
+	ProcessKafkaMessage(ctx context.Context, event string, message entity.Message) error
 }
 
 type Consumer struct {
@@ -210,8 +210,16 @@ func (c *Consumer) processSingleMessage(ctx context.Context, message kafka.Messa
 		return errors.New("invalid json message")
 	}
 
-	if err := c.processor.ProcessKafkaMessage(ctx, kafkaMessage); err != nil {
+	event := EventNewMessage
+	for _, header := range message.Headers {
+		if header.Key == HeaderEvent {
+			event = string(header.Value)
+		}
+	}
+
+	if err := c.processor.ProcessKafkaMessage(ctx, event, kafkaMessage); err != nil {
 		slog.Error("Failed to process Kafka message",
+			"event", event,
 			"message_id", kafkaMessage.ID,
 			"chat_id", kafkaMessage.ChatID,
 			"error", err)
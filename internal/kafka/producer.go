@@ -24,6 +24,15 @@ type Message struct {
 	Headers map[string]string
 }
 
+// header with type of event, messages without it are new messages
+const HeaderEvent = "event"
+
+const (
+	EventNewMessage     = "new_message"
+	EventMessageEdited  = "message_edited"
+	EventMessageDeleted = "message_deleted"
+)
+
 func NewProducer(cfg config.KafkaConfig) *Producer {
 	writer := &kafka.Writer{
 		Addr:                   kafka.TCP(cfg.Brokers...),
@@ -105,6 +114,11 @@ func (p *Producer) SendMessage(ctx context.Context, msg Message) error {
 
 // SendJSON send JSON message
 func (p *Producer) SendJSON(ctx context.Context, key string, value interface{}) error {
+	return p.SendEvent(ctx, key, "", value)
+}
+
+// SendEvent send JSON message with type of event in header
+func (p *Producer) SendEvent(ctx context.Context, key, event string, value interface{}) error {
 	jsonData, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
@@ -118,6 +132,9 @@ func (p *Producer) SendJSON(ctx context.Context, key string, value interface{})
 			"timestamp":    time.Now().UTC().Format(time.RFC3339),
 		},
 	}
+	if event != "" {
+		msg.Headers[HeaderEvent] = event
+	}
 
 	return p.SendMessage(ctx, msg)
 }
@@ -163,10 +180,14 @@ func (p *Producer) sendToDLQ(ctx context.Context, originalMsg Message, originalE
 }
 
 func (p *Producer) SendJSONWithRetry(ctx context.Context, key string, value interface{}, maxRetries int) error {
+	return p.SendEventWithRetry(ctx, key, "", value, maxRetries)
+}
+
+func (p *Producer) SendEventWithRetry(ctx context.Context, key, event string, value interface{}, maxRetries int) error {
 	var lastErr error
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err := p.SendJSON(ctx, key, value)
+		err := p.SendEvent(ctx, key, event, value)
 		if err == nil {
 			return nil
 		}
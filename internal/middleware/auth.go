@@ -20,6 +20,10 @@ type SessionRepositoryInterface interface {
 	DeleteSessionByUuid(uuid string) error
 }
 
+type TicketRepositoryInterface interface {
+	ConsumeWsTicket(ticket string) (payload.JwtPayload, error)
+}
+
 func AuthMiddleware(m JwtManagerInterface, s SessionRepositoryInterface) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -42,25 +46,72 @@ func AuthMiddleware(m JwtManagerInterface, s SessionRepositoryInterface) gin.Han
 			return
 		}
 
-		session, err := s.GetSessionByUuid(payload.Uuid)
-		if err != nil || session == nil {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Session not found"})
+		if !checkSession(c, s, payload) {
 			return
 		}
 
-		if time.Now().After(session.ExpiresAt) {
-			s.DeleteSessionByUuid(payload.Uuid)
-			c.AbortWithStatusJSON(401, gin.H{"error": "Session expired"})
-			return
+		c.Next()
+	}
+}
+
+// browsers can't set header on websocket upgrade, so besides header
+// access token may be passed in ?token= or single use ticket in ?ticket=
+func WsAuthMiddleware(m JwtManagerInterface, s SessionRepositoryInterface, t TicketRepositoryInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var p payload.JwtPayload
+		var err error
+
+		if ticket := c.Query("ticket"); ticket != "" {
+			p, err = t.ConsumeWsTicket(ticket)
+			if err != nil {
+				slog.Warn("invalid ws ticket", "error", err)
+				c.AbortWithStatusJSON(401, gin.H{"error": "Invalid ticket"})
+				return
+			}
+		} else {
+			token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if token == "" {
+				token = c.Query("token")
+			}
+			if token == "" {
+				slog.Warn("missing ws credentials")
+				c.AbortWithStatusJSON(401, gin.H{"error": "Authorization header, token or ticket required"})
+				return
+			}
+			p, err = m.Parse(token)
+			if err != nil {
+				c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+				return
+			}
 		}
 
-		c.Set("uuid", payload.Uuid)
-		c.Set("user_id", payload.UserId)
+		if !checkSession(c, s, p) {
+			return
+		}
 
 		c.Next()
 	}
 }
 
+// session must exist and be not expired, sets uuid and user_id to context
+func checkSession(c *gin.Context, s SessionRepositoryInterface, p payload.JwtPayload) bool {
+	session, err := s.GetSessionByUuid(p.Uuid)
+	if err != nil || session == nil {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Session not found"})
+		return false
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		s.DeleteSessionByUuid(p.Uuid)
+		c.AbortWithStatusJSON(401, gin.H{"error": "Session expired"})
+		return false
+	}
+
+	c.Set("uuid", p.Uuid)
+	c.Set("user_id", p.UserId)
+	return true
+}
+
 func AuthMiddlewareForRefresh(m JwtManagerInterface, s SessionRepositoryInterface) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
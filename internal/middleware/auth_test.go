@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/payload"
+)
+
+const testSession = "6f1c5a2e-3d4b-4c8a-9e7f-1a2b3c4d5e6f"
+
+// access token is valid when equals "valid"
+type fakeJwtManager struct{}
+
+func (fakeJwtManager) Parse(accessToken string) (payload.JwtPayload, error) {
+	if accessToken != "valid" {
+		return payload.JwtPayload{}, errors.New("invalid token")
+	}
+	return payload.JwtPayload{UserId: "1", Uuid: testSession}, nil
+}
+
+func (m fakeJwtManager) ParseIgnoreExpiration(accessToken string) (payload.JwtPayload, error) {
+	return m.Parse(accessToken)
+}
+
+type fakeSessionRepo struct{}
+
+func (fakeSessionRepo) GetSessionByUuid(uuid string) (*entity.Session, error) {
+	if uuid != testSession {
+		return nil, errors.New("session not found")
+	}
+	return &entity.Session{ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+func (fakeSessionRepo) DeleteSessionByUuid(uuid string) error { return nil }
+
+// tickets are deleted on use like in redis
+type fakeTicketRepo struct {
+	tickets map[string]payload.JwtPayload
+}
+
+func (r *fakeTicketRepo) ConsumeWsTicket(ticket string) (payload.JwtPayload, error) {
+	p, ok := r.tickets[ticket]
+	if !ok {
+		return payload.JwtPayload{}, errors.New("ws ticket not found or already used")
+	}
+	delete(r.tickets, ticket)
+	return p, nil
+}
+
+func TestWsAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		url      string
+		header   string
+		wantCode []int // of consecutive requests
+	}{
+		{name: "ticket is single use", url: "/ws?ticket=t1", wantCode: []int{200, 401}},
+		{name: "unknown ticket", url: "/ws?ticket=nope", wantCode: []int{401}},
+		{name: "ticket of unknown session", url: "/ws?ticket=stale", wantCode: []int{401}},
+		{name: "ticket is checked before token", url: "/ws?ticket=nope&token=valid", wantCode: []int{401}},
+		{name: "token in query can be reused", url: "/ws?token=valid", wantCode: []int{200, 200}},
+		{name: "invalid token in query", url: "/ws?token=bad", wantCode: []int{401}},
+		{name: "token in header", url: "/ws", header: "Bearer valid", wantCode: []int{200}},
+		{name: "no credentials", url: "/ws", wantCode: []int{401}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tickets := &fakeTicketRepo{tickets: map[string]payload.JwtPayload{
+				"t1":    {UserId: "1", Uuid: testSession},
+				"stale": {UserId: "1", Uuid: "deleted"},
+			}}
+			r := gin.New()
+			r.GET("/ws", WsAuthMiddleware(fakeJwtManager{}, fakeSessionRepo{}, tickets), func(c *gin.Context) {
+				if c.GetString("user_id") != "1" || c.GetString("uuid") != testSession {
+					t.Errorf("context user_id = %q, uuid = %q", c.GetString("user_id"), c.GetString("uuid"))
+				}
+				c.Status(http.StatusOK)
+			})
+
+			for i, want := range tt.wantCode {
+				req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+				if tt.header != "" {
+					req.Header.Set("Authorization", tt.header)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != want {
+					t.Errorf("request %d: code = %d, want %d", i+1, w.Code, want)
+				}
+			}
+		})
+	}
+}
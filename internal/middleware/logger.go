@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"log/slog"
+	"net/url"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,7 +12,7 @@ func LoggingMiddleware() gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		slog.Info("HTTP request",
 			"method", param.Method,
-			"path", param.Path,
+			"path", redactQuery(param.Path),
 			"status", param.StatusCode,
 			"latency", param.Latency,
 			"client_ip", param.ClientIP,
@@ -18,3 +20,21 @@ func LoggingMiddleware() gin.HandlerFunc {
 		return ""
 	})
 }
+
+// credentials of websocket connect must not get into logs
+func redactQuery(path string) string {
+	base, query, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return base
+	}
+	for _, key := range []string{"token", "ticket"} {
+		if values.Has(key) {
+			values.Set(key, "***")
+		}
+	}
+	return base + "?" + values.Encode()
+}
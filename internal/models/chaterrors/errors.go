@@ -29,6 +29,10 @@ var (
 	ErrFailedRemoveAdminOrOwnerByAdmin = errors.New("failed remove user, admin can't remove another admins or owner")
 	ErrFailedUpdateParticipant         = errors.New("failed update participant")
 	ErrFailedCheckParticipant          = errors.New("failed to check participant")
+	ErrMessageNotFound                 = errors.New("message not found in this chat")
+	ErrFailedPinMessage                = errors.New("failed pin message")
+	ErrFailedUnpinMessage              = errors.New("failed unpin message")
+	ErrFailedGetPins                   = errors.New("failed get pinned messages")
 
 	// service layer
 	ErrInvalidUser             = errors.New("invalid user_id")
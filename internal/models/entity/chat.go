@@ -34,6 +34,7 @@ type Chat struct {
 	IsPrivate      bool       `gorm:"default:false" json:"isPrivate"`
 	MaxMembers     int        `gorm:"default:100" json:"maxMembers"`
 	LastActivityAt *time.Time `gorm:"default:now()" json:"lastActivityAt"`
+	MessageTTL     int        `gorm:"column:message_ttl;default:0" json:"messageTtl"` // seconds messages live, 0 keeps them forever
 
 	// Relationships
 	Creator      *User              `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
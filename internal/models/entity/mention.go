@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// user mentioned in message by @tgname
+type MessageMention struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_message_mentions_unique,priority:1" json:"messageId"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_message_mentions_unique,priority:2;index:idx_message_mentions_user_chat,priority:1" json:"userId"`
+	ChatID    uint      `gorm:"not null;index:idx_message_mentions_user_chat,priority:2" json:"chatId"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Message *Message `gorm:"foreignKey:MessageID" json:"-"`
+	User    *User    `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (MessageMention) TableName() string {
+	return "message_mentions"
+}
+
+// telegram usernames are 5-32 letters, digits and underscores
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w{5,32})\b`)
+
+// lowercased tgnames mentioned in text, every name once
+func ParseMentions(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(match[1])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
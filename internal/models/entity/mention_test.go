@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "no mentions", text: "hello world", want: nil},
+		{name: "mention", text: "hi @alice_1", want: []string{"alice_1"}},
+		{name: "at start", text: "@alice_1, look", want: []string{"alice_1"}},
+		{name: "several in order", text: "@bobby_b and @alice_1", want: []string{"bobby_b", "alice_1"}},
+		{name: "lowercased once", text: "@Alice_1 @alice_1 @ALICE_1", want: []string{"alice_1"}},
+		{name: "too short", text: "@bob", want: nil},
+		{name: "too long is skipped", text: "@" + "a23456789012345678901234567890123", want: nil},
+		{name: "email is not mention", text: "mail me at me@example.com", want: nil},
+		{name: "double at", text: "@@alice_1", want: nil},
+		{name: "after punctuation", text: "(@alice_1)", want: []string{"alice_1"}},
+		{name: "cyrillic text around", text: "привет @alice_1!", want: []string{"alice_1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMentions(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package entity
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -33,6 +34,8 @@ type Message struct {
 	Content  string        `gorm:"type:text;not null" json:"content"`
 	Status   MessageStatus `gorm:"type:varchar(50);default:'sent'" json:"status"`
 	ClientID string        `gorm:"type:varchar(100);index" json:"clientId"`
+	// formatting of content, stored and returned as sent by client
+	Entities []MessageEntity `gorm:"type:jsonb;serializer:json" json:"entities,omitempty"`
 
 	FileURL  *string `gorm:"type:varchar(500)" json:"fileUrl,omitempty"`
 	FileName *string `gorm:"type:varchar(255)" json:"fileName,omitempty"`
@@ -41,6 +44,26 @@ type Message struct {
 
 	ReplyToID *uint `gorm:"index" json:"replyToId,omitempty"`
 
+	// source of forwarded copy, first message in chain of forwards
+	ForwardedFromChatID    *uint `json:"forwardedFromChatId,omitempty"`
+	ForwardedFromMessageID *uint `gorm:"index" json:"forwardedFromMessageId,omitempty"`
+	ForwardedFromUserID    *uint `json:"forwardedFromUserId,omitempty"`
+
+	EditedAt *time.Time `gorm:"type:timestamptz" json:"editedAt,omitempty"`
+	// set by consumer after broadcast, redelivered events are skipped
+	BroadcastAt *time.Time `gorm:"type:timestamptz" json:"-"`
+	DeletedBy   *uint      `json:"deletedBy,omitempty"`
+	// message of chat with ttl is removed by reaper after this time
+	ExpiresAt *time.Time `gorm:"type:timestamptz" json:"expiresAt,omitempty"`
+
+	// filled for reader of history, not stored in messages table
+	Reactions    []*ReactionCount `gorm:"-" json:"reactions,omitempty"`
+	ReplyCount   int64            `gorm:"-" json:"replyCount"`
+	ReplyPreview *MessagePreview  `gorm:"-" json:"replyPreview,omitempty"`
+	Receipts     *ReceiptCounts   `gorm:"-" json:"receipts,omitempty"`
+	// ids of mentioned users, set on send and passed to consumer
+	Mentions []uint `gorm:"-" json:"mentions,omitempty"`
+
 	// Relationships
 	Chat    *Chat      `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
 	User    *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -52,6 +75,65 @@ func (Message) TableName() string {
 	return "messages"
 }
 
+// expired message is hidden until reaper removes it
+func (m Message) Expired() bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(time.Now())
+}
+
+const previewLength = 100
+
+// short view of message shown above reply to it
+type MessagePreview struct {
+	ID      uint        `json:"id"`
+	UserID  uint        `json:"userId"`
+	Type    MessageType `json:"type"`
+	Content string      `json:"content"`
+	Deleted bool        `json:"deleted"`
+}
+
+func (m Message) Preview() *MessagePreview {
+	preview := &MessagePreview{
+		ID:     m.ID,
+		UserID: m.UserID,
+		Type:   m.Type,
+	}
+	if m.DeletedAt.Valid || m.Expired() {
+		preview.Deleted = true
+		return preview
+	}
+	content := []rune(m.Content)
+	if len(content) > previewLength {
+		content = append(content[:previewLength], '…')
+	}
+	preview.Content = string(content)
+	return preview
+}
+
+// copy of message sent by user to chat, keeps reference to original
+func (m Message) Forward(chatID, userID uint) Message {
+	forwarded := Message{
+		ChatID:                 chatID,
+		UserID:                 userID,
+		Type:                   m.Type,
+		Content:                m.Content,
+		Entities:               m.Entities,
+		Status:                 MessageStatusSent,
+		FileURL:                m.FileURL,
+		FileName:               m.FileName,
+		FileSize:               m.FileSize,
+		MimeType:               m.MimeType,
+		ForwardedFromChatID:    &m.ChatID,
+		ForwardedFromMessageID: &m.ID,
+		ForwardedFromUserID:    &m.UserID,
+	}
+	if m.ForwardedFromMessageID != nil {
+		forwarded.ForwardedFromChatID = m.ForwardedFromChatID
+		forwarded.ForwardedFromMessageID = m.ForwardedFromMessageID
+		forwarded.ForwardedFromUserID = m.ForwardedFromUserID
+	}
+	return forwarded
+}
+
 func (m Message) Validate() error {
 	if m.ChatID == 0 {
 		return errors.New("chatId is required")
@@ -69,6 +151,10 @@ func (m Message) Validate() error {
 		return errors.New("invalid message type")
 	}
 
+	if err := ValidateEntities(m.Content, m.Entities); err != nil {
+		return err
+	}
+
 	if !m.isValidStatus() {
 		return errors.New("invalid message status")
 	}
@@ -0,0 +1,19 @@
+package entity
+
+import "gorm.io/gorm"
+
+// previous version of message, saved on every edit
+type MessageEdit struct {
+	gorm.Model
+	MessageID uint   `gorm:"not null;index" json:"messageId"`
+	EditorID  uint   `gorm:"not null" json:"editorId"`
+	Content   string `gorm:"type:text;not null" json:"content"`
+
+	Entities []MessageEntity `gorm:"type:jsonb;serializer:json" json:"entities,omitempty"`
+
+	Message *Message `gorm:"foreignKey:MessageID" json:"-"`
+}
+
+func (MessageEdit) TableName() string {
+	return "message_edits"
+}
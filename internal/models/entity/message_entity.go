@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"unicode/utf16"
+)
+
+type MessageEntityType string
+
+const (
+	EntityBold          MessageEntityType = "bold"
+	EntityItalic        MessageEntityType = "italic"
+	EntityUnderline     MessageEntityType = "underline"
+	EntityStrikethrough MessageEntityType = "strikethrough"
+	EntityCode          MessageEntityType = "code"
+	EntityPre           MessageEntityType = "pre"
+	EntityTextLink      MessageEntityType = "text_link"
+	EntityMention       MessageEntityType = "mention"
+)
+
+const maxMessageEntities = 100
+
+// formatting span of content like telegram MessageEntity,
+// offset and length are counted in utf-16 code units
+type MessageEntity struct {
+	Type     MessageEntityType `json:"type"`
+	Offset   int               `json:"offset"`
+	Length   int               `json:"length"`
+	URL      *string           `json:"url,omitempty"`      // only for text_link
+	Language *string           `json:"language,omitempty"` // only for pre
+}
+
+// checks that every span lies inside content, spans may be nested
+func ValidateEntities(content string, entities []MessageEntity) error {
+	if len(entities) > maxMessageEntities {
+		return fmt.Errorf("too many entities, max %d", maxMessageEntities)
+	}
+	size := len(utf16.Encode([]rune(content)))
+	for i, e := range entities {
+		if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > size {
+			return fmt.Errorf("entity %d out of content bounds", i)
+		}
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("entity %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (e MessageEntity) validate() error {
+	switch e.Type {
+	case EntityBold, EntityItalic, EntityUnderline, EntityStrikethrough, EntityCode, EntityMention:
+	case EntityPre:
+		if e.Language != nil && len(*e.Language) > 32 {
+			return errors.New("language too long")
+		}
+	case EntityTextLink:
+		if e.URL == nil {
+			return errors.New("url is required for text_link")
+		}
+		u, err := url.Parse(*e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid url of text_link")
+		}
+	default:
+		return errors.New("invalid entity type")
+	}
+	if e.URL != nil && e.Type != EntityTextLink {
+		return errors.New("url allowed only for text_link")
+	}
+	if e.Language != nil && e.Type != EntityPre {
+		return errors.New("language allowed only for pre")
+	}
+	return nil
+}
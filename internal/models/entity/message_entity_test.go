@@ -0,0 +1,90 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateEntities(t *testing.T) {
+	str := func(s string) *string { return &s }
+	many := make([]MessageEntity, maxMessageEntities+1)
+	for i := range many {
+		many[i] = MessageEntity{Type: EntityBold, Offset: 0, Length: 1}
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		entities []MessageEntity
+		wantErr  bool
+	}{
+		{name: "no entities", content: "hello"},
+		{name: "whole content", content: "hello", entities: []MessageEntity{{Type: EntityBold, Offset: 0, Length: 5}}},
+		{
+			name:    "nested spans",
+			content: "hello world",
+			entities: []MessageEntity{
+				{Type: EntityBold, Offset: 0, Length: 11},
+				{Type: EntityItalic, Offset: 6, Length: 5},
+			},
+		},
+		{name: "cyrillic counted by characters", content: "привет", entities: []MessageEntity{{Type: EntityUnderline, Offset: 0, Length: 6}}},
+		// emoji outside basic plane is two utf-16 code units
+		{name: "emoji counted in utf-16", content: "hi 👍", entities: []MessageEntity{{Type: EntityBold, Offset: 3, Length: 2}}},
+		{name: "half of emoji past end", content: "hi 👍", entities: []MessageEntity{{Type: EntityBold, Offset: 3, Length: 3}}, wantErr: true},
+		{name: "past end", content: "hello", entities: []MessageEntity{{Type: EntityBold, Offset: 2, Length: 4}}, wantErr: true},
+		{name: "negative offset", content: "hello", entities: []MessageEntity{{Type: EntityBold, Offset: -1, Length: 2}}, wantErr: true},
+		{name: "empty span", content: "hello", entities: []MessageEntity{{Type: EntityBold, Offset: 1, Length: 0}}, wantErr: true},
+		{name: "unknown type", content: "hello", entities: []MessageEntity{{Type: "blink", Offset: 0, Length: 1}}, wantErr: true},
+		{name: "too many", content: "hello", entities: many, wantErr: true},
+		{
+			name:     "text link",
+			content:  "site",
+			entities: []MessageEntity{{Type: EntityTextLink, Offset: 0, Length: 4, URL: str("https://example.com/a?b=c")}},
+		},
+		{name: "text link without url", content: "site", entities: []MessageEntity{{Type: EntityTextLink, Offset: 0, Length: 4}}, wantErr: true},
+		{
+			name:     "text link with script",
+			content:  "site",
+			entities: []MessageEntity{{Type: EntityTextLink, Offset: 0, Length: 4, URL: str("javascript:alert(1)")}},
+			wantErr:  true,
+		},
+		{
+			name:     "text link without host",
+			content:  "site",
+			entities: []MessageEntity{{Type: EntityTextLink, Offset: 0, Length: 4, URL: str("https:///path")}},
+			wantErr:  true,
+		},
+		{name: "url of bold", content: "site", entities: []MessageEntity{{Type: EntityBold, Offset: 0, Length: 4, URL: str("https://example.com")}}, wantErr: true},
+		{name: "pre with language", content: "x := 1", entities: []MessageEntity{{Type: EntityPre, Offset: 0, Length: 6, Language: str("go")}}},
+		{name: "pre without language", content: "x := 1", entities: []MessageEntity{{Type: EntityPre, Offset: 0, Length: 6}}},
+		{
+			name:     "language too long",
+			content:  "x := 1",
+			entities: []MessageEntity{{Type: EntityPre, Offset: 0, Length: 6, Language: str(strings.Repeat("g", 33))}},
+			wantErr:  true,
+		},
+		{name: "language of code", content: "x := 1", entities: []MessageEntity{{Type: EntityCode, Offset: 0, Length: 6, Language: str("go")}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateEntities(tt.content, tt.entities); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEntities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageValidateChecksEntities(t *testing.T) {
+	message := Message{ChatID: 1, UserID: 2, Type: MessageTypeText, Status: MessageStatusSent, Content: "hi",
+		Entities: []MessageEntity{{Type: EntityBold, Offset: 0, Length: 3}}}
+	if err := message.Validate(); err == nil {
+		t.Error("message with entity past content is valid")
+	}
+
+	message.Entities[0].Length = 2
+	if err := message.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
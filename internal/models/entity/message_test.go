@@ -0,0 +1,138 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestMessageExpired(t *testing.T) {
+	past, future := time.Now().Add(-time.Second), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		expires *time.Time
+		want    bool
+	}{
+		{name: "message of chat without ttl", expires: nil, want: false},
+		{name: "not expired yet", expires: &future, want: false},
+		{name: "expired", expires: &past, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Message{ExpiresAt: tt.expires}).Expired(); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessagePreview(t *testing.T) {
+	long := strings.Repeat("я", previewLength+1)
+	expired, notExpired := time.Now().Add(-time.Second), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		message     Message
+		wantContent string
+		wantDeleted bool
+	}{
+		{
+			name:        "short content is kept",
+			message:     Message{Content: "hello"},
+			wantContent: "hello",
+		},
+		{
+			name:        "content of preview length is kept",
+			message:     Message{Content: long[:len(long)-len("я")]},
+			wantContent: long[:len(long)-len("я")],
+		},
+		{
+			name:        "long content is cut by runes",
+			message:     Message{Content: long},
+			wantContent: strings.Repeat("я", previewLength) + "…",
+		},
+		{
+			name:        "deleted message has no content",
+			message:     Message{Model: gorm.Model{DeletedAt: gorm.DeletedAt{Valid: true}}, Content: "hello"},
+			wantDeleted: true,
+		},
+		{
+			name:        "expired message has no content",
+			message:     Message{Content: "hello", ExpiresAt: &expired},
+			wantDeleted: true,
+		},
+		{
+			name:        "message with ttl before expiry",
+			message:     Message{Content: "hello", ExpiresAt: &notExpired},
+			wantContent: "hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.message.ID = 7
+			tt.message.UserID = 3
+			tt.message.Type = MessageTypeText
+
+			got := tt.message.Preview()
+			if got.ID != 7 || got.UserID != 3 || got.Type != MessageTypeText {
+				t.Errorf("Preview() = %+v, want id 7 of user 3", got)
+			}
+			if got.Content != tt.wantContent || got.Deleted != tt.wantDeleted {
+				t.Errorf("Preview() content = %q, deleted = %v, want %q, %v",
+					got.Content, got.Deleted, tt.wantContent, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestMessageForward(t *testing.T) {
+	url := "https://example.com/a.png"
+	original := Message{
+		Model:    gorm.Model{ID: 10},
+		ChatID:   1,
+		UserID:   2,
+		Type:     MessageTypeImage,
+		Content:  "look",
+		Entities: []MessageEntity{{Type: EntityBold, Offset: 0, Length: 4}},
+		Status:   MessageStatusRead,
+		FileURL:  &url,
+	}
+	forwarded := original.Forward(5, 6)
+	forwarded.ID = 11
+
+	tests := []struct {
+		name       string
+		message    Message
+		wantSource [3]uint // chat, message and author of original
+	}{
+		{name: "original message", message: original, wantSource: [3]uint{1, 10, 2}},
+		{name: "forward of forward keeps first source", message: forwarded, wantSource: [3]uint{1, 10, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.message.Forward(7, 8)
+
+			if got.ID != 0 || got.ChatID != 7 || got.UserID != 8 || got.Status != MessageStatusSent {
+				t.Errorf("copy = id %d, chat %d, user %d, status %s, want new message of user 8 in chat 7",
+					got.ID, got.ChatID, got.UserID, got.Status)
+			}
+			if got.Type != MessageTypeImage || got.Content != "look" || got.FileURL == nil || *got.FileURL != url || len(got.Entities) != 1 {
+				t.Errorf("copy = %+v, want content of original", got)
+			}
+			if got.ForwardedFromChatID == nil || got.ForwardedFromMessageID == nil || got.ForwardedFromUserID == nil {
+				t.Fatalf("copy has no source")
+			}
+			source := [3]uint{*got.ForwardedFromChatID, *got.ForwardedFromMessageID, *got.ForwardedFromUserID}
+			if source != tt.wantSource {
+				t.Errorf("source = %v, want %v", source, tt.wantSource)
+			}
+		})
+	}
+
+}
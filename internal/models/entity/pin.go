@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// message pinned in chat by admin or owner
+type ChatPin struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ChatID    uint      `gorm:"not null;uniqueIndex:idx_chat_pins_unique,priority:1" json:"chatId"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_chat_pins_unique,priority:2" json:"messageId"`
+	PinnedBy  uint      `gorm:"not null" json:"pinnedBy"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Chat    *Chat    `gorm:"foreignKey:ChatID" json:"-"`
+	Message *Message `gorm:"foreignKey:MessageID" json:"-"`
+}
+
+func (ChatPin) TableName() string {
+	return "chat_pins"
+}
@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"errors"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxEmojiLength = 32
+
+// one emoji of user on message, user can put several different emoji
+type MessageReaction struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_message_reactions_unique,priority:1" json:"messageId"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_message_reactions_unique,priority:2;index" json:"userId"`
+	Emoji     string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_message_reactions_unique,priority:3" json:"emoji"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Message *Message `gorm:"foreignKey:MessageID" json:"-"`
+	User    *User    `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (MessageReaction) TableName() string {
+	return "message_reactions"
+}
+
+func (r MessageReaction) Validate() error {
+	if r.MessageID == 0 {
+		return errors.New("messageId is required")
+	}
+	if r.UserID == 0 {
+		return errors.New("userId is required")
+	}
+	return ValidateEmoji(r.Emoji)
+}
+
+func ValidateEmoji(emoji string) error {
+	if emoji == "" {
+		return errors.New("emoji is required")
+	}
+	if len(emoji) > maxEmojiLength || !utf8.ValidString(emoji) {
+		return errors.New("invalid emoji")
+	}
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsLetter(r) {
+			return errors.New("invalid emoji")
+		}
+	}
+	return nil
+}
+
+// reactions of message grouped by emoji
+type ReactionCount struct {
+	Emoji       string `json:"emoji"`
+	Count       int64  `json:"count"`
+	ReactedByMe bool   `json:"reactedByMe"`
+}
@@ -0,0 +1,52 @@
+package entity
+
+import "testing"
+
+func TestValidateEmoji(t *testing.T) {
+	tests := []struct {
+		name    string
+		emoji   string
+		wantErr bool
+	}{
+		{name: "emoji", emoji: "👍"},
+		{name: "emoji with skin tone", emoji: "👍🏽"},
+		{name: "emoji with variation selector", emoji: "❤️"},
+		{name: "zwj sequence", emoji: "👨‍👩‍👧"},
+		{name: "flag", emoji: "🇷🇺"},
+		{name: "empty", emoji: "", wantErr: true},
+		{name: "letters", emoji: "ok", wantErr: true},
+		{name: "emoji with letter", emoji: "👍a", wantErr: true},
+		{name: "space", emoji: "👍 ", wantErr: true},
+		{name: "too long", emoji: "👍👍👍👍👍👍👍👍👍", wantErr: true},
+		{name: "invalid utf8", emoji: "\xff", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateEmoji(tt.emoji); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEmoji(%q) error = %v, wantErr %v", tt.emoji, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageReactionValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		reaction MessageReaction
+		wantErr  bool
+	}{
+		{name: "valid", reaction: MessageReaction{MessageID: 1, UserID: 2, Emoji: "🔥"}},
+		{name: "without message", reaction: MessageReaction{UserID: 2, Emoji: "🔥"}, wantErr: true},
+		{name: "without user", reaction: MessageReaction{MessageID: 1, Emoji: "🔥"}, wantErr: true},
+		{name: "invalid emoji", reaction: MessageReaction{MessageID: 1, UserID: 2, Emoji: "fire"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.reaction.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
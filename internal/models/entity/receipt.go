@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// delivery and reading of message by one recipient
+type MessageReceipt struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	MessageID   uint       `gorm:"not null;uniqueIndex:idx_message_receipts_unique,priority:1" json:"messageId"`
+	UserID      uint       `gorm:"not null;uniqueIndex:idx_message_receipts_unique,priority:2;index" json:"userId"`
+	DeliveredAt *time.Time `gorm:"type:timestamptz" json:"deliveredAt,omitempty"`
+	ReadAt      *time.Time `gorm:"type:timestamptz" json:"readAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+
+	Message *Message `gorm:"foreignKey:MessageID" json:"-"`
+	User    *User    `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (MessageReceipt) TableName() string {
+	return "message_receipts"
+}
+
+// recipients who got and read message
+type ReceiptCounts struct {
+	Delivered int64 `json:"delivered"`
+	Read      int64 `json:"read"`
+}
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ScheduledStatus string
+
+const (
+	ScheduledStatusPending ScheduledStatus = "pending"
+	ScheduledStatusSending ScheduledStatus = "sending" // claimed by scheduler of one replica
+	ScheduledStatusSent    ScheduledStatus = "sent"
+	ScheduledStatusFailed  ScheduledStatus = "failed"
+)
+
+// message sent by scheduler at SendAt, deleting cancels it
+type ScheduledMessage struct {
+	gorm.Model
+	ChatID   uint            `gorm:"not null;index" json:"chatId"`
+	UserID   uint            `gorm:"not null;index" json:"userId"`
+	Type     MessageType     `gorm:"type:varchar(50);default:'text'" json:"type"`
+	Content  string          `gorm:"type:text;not null" json:"content"`
+	Entities []MessageEntity `gorm:"type:jsonb;serializer:json" json:"entities,omitempty"`
+	SendAt   time.Time       `gorm:"type:timestamptz;not null" json:"sendAt"`
+	Status   ScheduledStatus `gorm:"type:varchar(50);default:'pending'" json:"status"`
+	ClientID string          `gorm:"type:varchar(100)" json:"clientId"`
+
+	FileURL  *string `gorm:"type:varchar(500)" json:"fileUrl,omitempty"`
+	FileName *string `gorm:"type:varchar(255)" json:"fileName,omitempty"`
+	FileSize *int64  `json:"fileSize,omitempty"`
+	MimeType *string `gorm:"type:varchar(100)" json:"mimeType,omitempty"`
+
+	ReplyToID *uint `json:"replyToId,omitempty"`
+
+	ClaimedAt *time.Time `gorm:"type:timestamptz" json:"-"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`
+
+	// reason of failure at send time, e.g. user left chat
+	Error *string `gorm:"type:varchar(255)" json:"error,omitempty"`
+
+	Chat *Chat `gorm:"foreignKey:ChatID" json:"-"`
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (ScheduledMessage) TableName() string {
+	return "scheduled_messages"
+}
@@ -22,13 +22,20 @@ type UpdateChatRequest struct {
 	Description *string `json:"description,omitempty"`
 	AvatarURL   *string `json:"avatarUrl,omitempty"`
 	IsPrivate   bool    `json:"is_private,omitempty"`
+	MessageTTL  *int    `json:"message_ttl,omitempty"` // seconds, 0 turns disappearing messages off
 }
 
+const MaxMessageTTL = 365 * 24 * 60 * 60
+
 func (r UpdateChatRequest) Validate() error {
 	if r.Id == "" {
 		slog.Error("chat_id is required")
 		return errors.New("chat_id is required")
 	}
+	if r.MessageTTL != nil && (*r.MessageTTL < 0 || *r.MessageTTL > MaxMessageTTL) {
+		slog.Error("invalid message_ttl", "message_ttl", *r.MessageTTL)
+		return errors.New("message_ttl must be between 0 and one year in seconds")
+	}
 	return nil
 }
 
@@ -46,6 +53,23 @@ func (r ChatRequest) Validate() error {
 	return nil
 }
 
+type PinRequest struct {
+	Id        string `json:"chat_id"`
+	MessageID uint   `json:"message_id"`
+}
+
+func (r PinRequest) Validate() error {
+	if r.Id == "" {
+		slog.Error("chat_id is required")
+		return errors.New("chat_id is required")
+	}
+	if r.MessageID == 0 {
+		slog.Error("message_id is required")
+		return errors.New("message_id is required")
+	}
+	return nil
+}
+
 type Participant struct {
 	ID string `json:"user_id"`
 }
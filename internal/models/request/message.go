@@ -1,15 +1,229 @@
 package request
 
-import "github.com/sibhellyx/Messenger/internal/models/entity"
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+)
 
 type CreateMessage struct {
-	ChatID    string             `json:"chatId" binding:"required"`
-	Content   string             `json:"content" binding:"required"`
-	Type      entity.MessageType `json:"type" binding:"required,oneof=text image file system"`
-	ReplyToID *uint              `json:"replyToId,omitempty"`
-	FileURL   *string            `json:"fileUrl,omitempty"`
-	FileName  *string            `json:"fileName,omitempty"`
-	FileSize  *int64             `json:"fileSize,omitempty"`
-	MimeType  *string            `json:"mimeType,omitempty"`
-	ClientID  string             `json:"clientId" binding:"required"`
+	ChatID    string                 `json:"chatId" binding:"required"`
+	Content   string                 `json:"content" binding:"required"`
+	Entities  []entity.MessageEntity `json:"entities,omitempty"`
+	Type      entity.MessageType     `json:"type" binding:"required,oneof=text image file system"`
+	ReplyToID *uint                  `json:"replyToId,omitempty"`
+	FileURL   *string                `json:"fileUrl,omitempty"`
+	FileName  *string                `json:"fileName,omitempty"`
+	FileSize  *int64                 `json:"fileSize,omitempty"`
+	MimeType  *string                `json:"mimeType,omitempty"`
+	ClientID  string                 `json:"clientId" binding:"required"`
+}
+
+type ReadMessagesRequest struct {
+	ChatID    string `json:"chat_id"`
+	MessageID uint   `json:"message_id"`
+}
+
+func (r ReadMessagesRequest) Validate() error {
+	slog.Debug("validating read messages request")
+	if r.ChatID == "" {
+		slog.Error("chat_id is required")
+		return errors.New("chat_id is required")
+	}
+	if r.MessageID == 0 {
+		slog.Error("message_id is required")
+		return errors.New("message_id is required")
+	}
+	return nil
+}
+
+type TypingRequest struct {
+	ChatID string `json:"chat_id"`
+}
+
+func (r TypingRequest) Validate() error {
+	if r.ChatID == "" {
+		slog.Error("chat_id is required")
+		return errors.New("chat_id is required")
+	}
+	return nil
+}
+
+type EditMessage struct {
+	MessageID uint                   `json:"messageId"`
+	Content   string                 `json:"content"`
+	Entities  []entity.MessageEntity `json:"entities,omitempty"`
+}
+
+func (r EditMessage) Validate() error {
+	if r.MessageID == 0 {
+		slog.Error("messageId is required")
+		return errors.New("messageId is required")
+	}
+	if r.Content == "" {
+		slog.Error("content is required")
+		return errors.New("content is required")
+	}
+	return nil
+}
+
+type DeleteMessage struct {
+	MessageID uint `json:"messageId"`
+}
+
+func (r DeleteMessage) Validate() error {
+	if r.MessageID == 0 {
+		slog.Error("messageId is required")
+		return errors.New("messageId is required")
+	}
+	return nil
+}
+
+const (
+	DefaultMessagesLimit = 50
+	MaxMessagesLimit     = 200
+)
+
+// cursor of chat history, before_id and after_id are exclusive bounds
+type MessagesPage struct {
+	BeforeID uint `form:"before_id"`
+	AfterID  uint `form:"after_id"`
+	Limit    int  `form:"limit"`
+}
+
+func (p *MessagesPage) Validate() error {
+	if p.BeforeID != 0 && p.AfterID != 0 {
+		slog.Error("before_id and after_id can't be used together")
+		return errors.New("before_id and after_id can't be used together")
+	}
+	if p.Limit < 0 {
+		slog.Error("limit must be positive")
+		return errors.New("limit must be positive")
+	}
+	if p.Limit == 0 {
+		p.Limit = DefaultMessagesLimit
+	}
+	if p.Limit > MaxMessagesLimit {
+		p.Limit = MaxMessagesLimit
+	}
+	return nil
+}
+
+// page goes to newer messages, otherwise to older
+func (p MessagesPage) Forward(since bool) bool {
+	return p.AfterID != 0 || (since && p.BeforeID == 0)
+}
+
+type ReactionRequest struct {
+	MessageID uint   `json:"messageId"`
+	Emoji     string `json:"emoji"`
+}
+
+func (r ReactionRequest) Validate() error {
+	if r.MessageID == 0 {
+		slog.Error("messageId is required")
+		return errors.New("messageId is required")
+	}
+	return entity.ValidateEmoji(r.Emoji)
+}
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	maxSearchQuery     = 256
+)
+
+type SearchMessages struct {
+	Query  string `form:"q"`
+	ChatID uint   `form:"chat_id"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+func (r *SearchMessages) Validate() error {
+	r.Query = strings.TrimSpace(r.Query)
+	if r.Query == "" {
+		slog.Error("search query is required")
+		return errors.New("search query is required")
+	}
+	if len(r.Query) > maxSearchQuery {
+		slog.Error("search query too long", "length", len(r.Query))
+		return errors.New("search query too long")
+	}
+	if r.Limit < 0 || r.Offset < 0 {
+		slog.Error("limit and offset must be positive")
+		return errors.New("limit and offset must be positive")
+	}
+	if r.Limit == 0 {
+		r.Limit = DefaultSearchLimit
+	}
+	if r.Limit > MaxSearchLimit {
+		r.Limit = MaxSearchLimit
+	}
+	return nil
+}
+
+const MaxForwardMessages = 100
+
+type ForwardMessages struct {
+	ChatID     string `json:"chatId"`
+	MessageIDs []uint `json:"messageIds"`
+}
+
+func (r ForwardMessages) Validate() error {
+	if r.ChatID == "" {
+		slog.Error("chatId is required")
+		return errors.New("chatId is required")
+	}
+	if len(r.MessageIDs) == 0 {
+		slog.Error("messageIds is required")
+		return errors.New("messageIds is required")
+	}
+	if len(r.MessageIDs) > MaxForwardMessages {
+		slog.Error("too many messages to forward", "count", len(r.MessageIDs))
+		return errors.New("too many messages to forward")
+	}
+	for _, id := range r.MessageIDs {
+		if id == 0 {
+			slog.Error("invalid message id in messageIds")
+			return errors.New("invalid message id in messageIds")
+		}
+	}
+	return nil
+}
+
+type ScheduleMessage struct {
+	CreateMessage
+	SendAt time.Time `json:"sendAt"`
+}
+
+func (r ScheduleMessage) Validate() error {
+	if r.ChatID == "" {
+		slog.Error("chatId is required")
+		return errors.New("chatId is required")
+	}
+	if r.SendAt.IsZero() {
+		slog.Error("sendAt is required")
+		return errors.New("sendAt is required")
+	}
+	if !r.SendAt.After(time.Now()) {
+		slog.Error("sendAt must be in the future", "send_at", r.SendAt)
+		return errors.New("sendAt must be in the future")
+	}
+	return nil
+}
+
+type CancelScheduled struct {
+	ID uint `json:"id"`
+}
+
+func (r CancelScheduled) Validate() error {
+	if r.ID == 0 {
+		slog.Error("id is required")
+		return errors.New("id is required")
+	}
+	return nil
 }
@@ -0,0 +1,54 @@
+package request
+
+import "testing"
+
+func TestMessagesPageValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      MessagesPage
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "default limit", page: MessagesPage{}, wantLimit: DefaultMessagesLimit},
+		{name: "limit is kept", page: MessagesPage{Limit: 10}, wantLimit: 10},
+		{name: "limit is capped", page: MessagesPage{Limit: MaxMessagesLimit + 1}, wantLimit: MaxMessagesLimit},
+		{name: "before id", page: MessagesPage{BeforeID: 5, Limit: 10}, wantLimit: 10},
+		{name: "negative limit", page: MessagesPage{Limit: -1}, wantErr: true},
+		{name: "both cursors", page: MessagesPage{BeforeID: 5, AfterID: 3}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.page.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.page.Limit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", tt.page.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestMessagesPageForward(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  MessagesPage
+		since bool
+		want  bool
+	}{
+		{name: "latest messages", page: MessagesPage{}, want: false},
+		{name: "older than cursor", page: MessagesPage{BeforeID: 5}, want: false},
+		{name: "newer than cursor", page: MessagesPage{AfterID: 5}, want: true},
+		{name: "since time", page: MessagesPage{}, since: true, want: true},
+		{name: "since time before cursor", page: MessagesPage{BeforeID: 5}, since: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.page.Forward(tt.since); got != tt.want {
+				t.Errorf("Forward(%v) = %v, want %v", tt.since, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package response
+
+import (
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+)
+
+type UserChat struct {
+	*entity.Chat
+	UnreadCount int64 `json:"unreadCount"`
+}
+
+type PinnedMessage struct {
+	*entity.Message
+	PinnedBy uint      `json:"pinnedBy"`
+	PinnedAt time.Time `json:"pinnedAt"`
+}
@@ -0,0 +1,9 @@
+package response
+
+import "github.com/sibhellyx/Messenger/internal/models/entity"
+
+type MessageSearchResult struct {
+	*entity.Message
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"` // html-escaped content, matched words wrapped in <b></b>
+}
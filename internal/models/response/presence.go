@@ -0,0 +1,9 @@
+package response
+
+import "time"
+
+type Presence struct {
+	UserID     uint       `json:"userId"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+}
@@ -23,3 +23,8 @@ func (t Tokens) Validate() error {
 	slog.Debug("validating tokens completed")
 	return nil
 }
+
+type WsTicket struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expiresIn"` // seconds
+}
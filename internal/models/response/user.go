@@ -10,4 +10,5 @@ type UserWithProfile struct {
 	Avatar      string     `json:"avatar"`
 	Bio         string     `json:"bio"`
 	DateOfBirth *time.Time `json:"date_of_birth"`
+	LastSeenAt  *time.Time `json:"lastSeenAt,omitempty"`
 }
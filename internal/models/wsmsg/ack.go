@@ -0,0 +1,9 @@
+package wsmsg
+
+import "github.com/sibhellyx/Messenger/internal/models/entity"
+
+// outgoing message shown on other devices of sender before it is delivered
+type MessageAckMsg struct {
+	Type    string          `json:"type"`
+	Message *entity.Message `json:"message"`
+}
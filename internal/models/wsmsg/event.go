@@ -0,0 +1,15 @@
+package wsmsg
+
+// event saved for replay, seq grows monotonically per user
+type Event struct {
+	Seq       uint64
+	Data      []byte
+	MessageID uint // chat message in event, 0 for other events
+}
+
+// sent instead of replay when missed events are not kept anymore,
+// client must reload state and continue from seq
+type ResyncMsg struct {
+	Type string `json:"type"`
+	Seq  uint64 `json:"seq"`
+}
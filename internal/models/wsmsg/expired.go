@@ -0,0 +1,8 @@
+package wsmsg
+
+// messages removed by ttl of chat, clients must purge them
+type ExpiredMsg struct {
+	Type       string `json:"type"`
+	ChatID     uint   `json:"chat_id"`
+	MessageIDs []uint `json:"message_ids"`
+}
@@ -0,0 +1,19 @@
+package wsmsg
+
+// sent after new_message to participants who did not mute chat
+type NotificationMsg struct {
+	Type      string `json:"type"`
+	ChatID    uint   `json:"chat_id"`
+	MessageID uint   `json:"message_id"`
+	UserID    uint   `json:"user_id"` // author of message
+	Content   string `json:"content"`
+}
+
+// sent to mentioned user even if chat is muted
+type MentionMsg struct {
+	Type      string `json:"type"`
+	ChatID    uint   `json:"chat_id"`
+	MessageID uint   `json:"message_id"`
+	UserID    uint   `json:"user_id"` // author of message
+	Content   string `json:"content"`
+}
@@ -0,0 +1,8 @@
+package wsmsg
+
+type PinMsg struct {
+	Type      string `json:"type"` // message_pinned or message_unpinned
+	ChatID    uint   `json:"chat_id"`
+	MessageID uint   `json:"message_id"`
+	UserID    uint   `json:"user_id"`
+}
@@ -0,0 +1,10 @@
+package wsmsg
+
+import "time"
+
+type PresenceMsg struct {
+	UserID     uint       `json:"user_id"`
+	Type       string     `json:"type"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
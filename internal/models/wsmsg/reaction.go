@@ -0,0 +1,11 @@
+package wsmsg
+
+type ReactionMsg struct {
+	Type      string `json:"type"`
+	ChatID    uint   `json:"chat_id"`
+	MessageID uint   `json:"message_id"`
+	UserID    uint   `json:"user_id"`
+	Emoji     string `json:"emoji"`
+	Added     bool   `json:"added"`
+	Count     int64  `json:"count"` // users with this emoji after change
+}
@@ -0,0 +1,11 @@
+package wsmsg
+
+import "time"
+
+type ReadReceiptMsg struct {
+	Type      string    `json:"type"`
+	ChatID    uint      `json:"chat_id"`
+	UserID    uint      `json:"user_id"`
+	MessageID uint      `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
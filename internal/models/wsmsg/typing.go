@@ -0,0 +1,8 @@
+package wsmsg
+
+type TypingMsg struct {
+	ChatID uint   `json:"chat_id"`
+	UserID uint   `json:"user_id"`
+	Type   string `json:"type"`
+	Typing bool   `json:"typing"`
+}
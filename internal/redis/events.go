@@ -0,0 +1,110 @@
+package redispkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+)
+
+// last sequence number of user events, never expires so numbers are not reused
+func eventSeqKey(userID uint) string {
+	return fmt.Sprintf("event_seq:%d", userID)
+}
+
+// stream of recent user events, entry id is "<seq>-0"
+func eventsKey(userID uint) string {
+	return fmt.Sprintf("events:%d", userID)
+}
+
+// increment sequence and save event for every user in one step
+var appendEventsScript = redis.NewScript(`
+local seqs = {}
+for i = 1, #KEYS, 2 do
+	local seq = redis.call('INCR', KEYS[i])
+	redis.call('XADD', KEYS[i + 1], 'MAXLEN', '~', ARGV[2], seq .. '-0', 'data', ARGV[1], 'message_id', ARGV[4])
+	redis.call('EXPIRE', KEYS[i + 1], ARGV[3])
+	seqs[#seqs + 1] = seq
+end
+return seqs
+`)
+
+// save event for users, returns sequence number of event for every user
+func (r *RedisRepository) AppendEvents(userIDs []uint, data []byte, messageID uint, maxLen int64, ttl time.Duration) (map[uint]uint64, error) {
+	if len(userIDs) == 0 {
+		return map[uint]uint64{}, nil
+	}
+
+	keys := make([]string, 0, len(userIDs)*2)
+	for _, id := range userIDs {
+		keys = append(keys, eventSeqKey(id), eventsKey(id))
+	}
+
+	seqs, err := appendEventsScript.Run(r.ctx, r.client.client, keys, data, maxLen, int64(ttl.Seconds()), messageID).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to append events: %w", err)
+	}
+
+	result := make(map[uint]uint64, len(userIDs))
+	for i, id := range userIDs {
+		result[id] = uint64(seqs[i])
+	}
+	return result, nil
+}
+
+// events of user after since, returns last sequence number of user
+// and false if some of missed events are not kept anymore
+func (r *RedisRepository) GetEventsSince(userID uint, since uint64) ([]wsmsg.Event, uint64, bool, error) {
+	var last *redis.StringCmd
+	var entries *redis.XMessageSliceCmd
+	_, err := r.client.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		last = pipe.Get(r.ctx, eventSeqKey(userID))
+		entries = pipe.XRange(r.ctx, eventsKey(userID), fmt.Sprintf("%d-0", since+1), "+")
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, 0, false, fmt.Errorf("failed to get events: %w", err)
+	}
+
+	current, err := last.Uint64()
+	if err != nil && err != redis.Nil {
+		return nil, 0, false, fmt.Errorf("failed to parse event seq: %w", err)
+	}
+	if since > current {
+		// client knows more than server, state was lost
+		return nil, current, false, nil
+	}
+
+	events := make([]wsmsg.Event, 0, len(entries.Val()))
+	for _, entry := range entries.Val() {
+		seq, err := parseEventSeq(entry.ID)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		data, _ := entry.Values["data"].(string)
+		event := wsmsg.Event{Seq: seq, Data: []byte(data)}
+		if messageID, ok := entry.Values["message_id"].(string); ok {
+			id, _ := strconv.ParseUint(messageID, 10, 32)
+			event.MessageID = uint(id)
+		}
+		events = append(events, event)
+	}
+
+	missed := current - since
+	if uint64(len(events)) < missed || (len(events) > 0 && events[0].Seq != since+1) {
+		return nil, current, false, nil
+	}
+	return events, current, true, nil
+}
+
+func parseEventSeq(id string) (uint64, error) {
+	seqPart, _, _ := strings.Cut(id, "-")
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid event id %q: %w", id, err)
+	}
+	return seq, nil
+}
@@ -0,0 +1,70 @@
+package redispkg
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetEventsSince(t *testing.T) {
+	r := newTestRepository(t)
+
+	// users of test run are unique, sequences in redis are never reset
+	base := uint(time.Now().UnixNano() % 1_000_000_000)
+	user, lost, empty := base+1, base+2, base+3
+
+	for i, data := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		seqs, err := r.AppendEvents([]uint{user, lost}, []byte(data), uint(i+100), 100, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seqs[user] != uint64(i+1) || seqs[lost] != uint64(i+1) {
+			t.Fatalf("seqs = %v, want %d for both users", seqs, i+1)
+		}
+	}
+	// stream of user expired, sequence is kept
+	if err := r.client.client.Del(r.ctx, eventsKey(lost)).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		userID       uint
+		since        uint64
+		want         []uint64
+		wantLast     uint64
+		wantComplete bool
+	}{
+		{name: "all events", userID: user, since: 0, want: []uint64{1, 2, 3}, wantLast: 3, wantComplete: true},
+		{name: "missed events", userID: user, since: 1, want: []uint64{2, 3}, wantLast: 3, wantComplete: true},
+		{name: "nothing missed", userID: user, since: 3, wantLast: 3, wantComplete: true},
+		{name: "client ahead of server", userID: user, since: 5, wantLast: 3, wantComplete: false},
+		{name: "events not kept", userID: lost, since: 1, wantLast: 3, wantComplete: false},
+		{name: "user without events", userID: empty, since: 0, wantLast: 0, wantComplete: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, last, complete, err := r.GetEventsSince(tt.userID, tt.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []uint64
+			for _, event := range events {
+				got = append(got, event.Seq)
+				if want := fmt.Sprintf(`{"n":%d}`, event.Seq); string(event.Data) != want {
+					t.Errorf("event %d data = %s, want %s", event.Seq, event.Data, want)
+				}
+				if event.MessageID != uint(event.Seq+99) {
+					t.Errorf("event %d message id = %d, want %d", event.Seq, event.MessageID, event.Seq+99)
+				}
+			}
+			if !slices.Equal(got, tt.want) || last != tt.wantLast || complete != tt.wantComplete {
+				t.Errorf("GetEventsSince() = %v, %d, %v, want %v, %d, %v",
+					got, last, complete, tt.want, tt.wantLast, tt.wantComplete)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package redispkg
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sibhellyx/Messenger/internal/models/response"
+)
+
+// presence of user stored as sorted set of his sessions, score is expiration time
+func presenceKey(userID uint) string {
+	return fmt.Sprintf("presence:%d", userID)
+}
+
+func lastSeenKey(userID uint) string {
+	return fmt.Sprintf("last_seen:%d", userID)
+}
+
+// prolong session presence, returns true if user was offline before
+func (r *RedisRepository) TouchPresence(userID uint, session string, ttl time.Duration) (bool, error) {
+	key := presenceKey(userID)
+	now := time.Now()
+
+	var before *redis.IntCmd
+	_, err := r.client.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(r.ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		before = pipe.ZCard(r.ctx, key)
+		pipe.ZAdd(r.ctx, key, redis.Z{Score: float64(now.Add(ttl).UnixMilli()), Member: session})
+		pipe.Expire(r.ctx, key, ttl)
+		pipe.Set(r.ctx, lastSeenKey(userID), now.Unix(), 0)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to touch presence: %w", err)
+	}
+	return before.Val() == 0, nil
+}
+
+// remove session from presence, returns true if user has no more live sessions
+func (r *RedisRepository) RemovePresence(userID uint, session string) (bool, error) {
+	key := presenceKey(userID)
+	now := time.Now()
+
+	var after *redis.IntCmd
+	_, err := r.client.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(r.ctx, key, session)
+		pipe.ZRemRangeByScore(r.ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		after = pipe.ZCard(r.ctx, key)
+		pipe.Set(r.ctx, lastSeenKey(userID), now.Unix(), 0)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to remove presence: %w", err)
+	}
+	return after.Val() == 0, nil
+}
+
+func (r *RedisRepository) GetPresence(userIDs []uint) ([]*response.Presence, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	online := make([]*redis.IntCmd, len(userIDs))
+	lastSeen := make([]*redis.StringCmd, len(userIDs))
+	_, err := r.client.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range userIDs {
+			online[i] = pipe.ZCount(r.ctx, presenceKey(id), "("+now, "+inf")
+			lastSeen[i] = pipe.Get(r.ctx, lastSeenKey(id))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get presence: %w", err)
+	}
+
+	result := make([]*response.Presence, 0, len(userIDs))
+	for i, id := range userIDs {
+		presence := &response.Presence{
+			UserID: id,
+			Online: online[i].Val() > 0,
+		}
+		if ts, err := lastSeen[i].Int64(); err == nil {
+			t := time.Unix(ts, 0)
+			presence.LastSeenAt = &t
+		}
+		result = append(result, presence)
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package redispkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresenceOfSessions(t *testing.T) {
+	tests := []struct {
+		name       string
+		run        func(r *RedisRepository, userID uint) []bool // results of steps
+		want       []bool
+		wantOnline bool
+	}{
+		{
+			name: "first session brings user online",
+			run: func(r *RedisRepository, userID uint) []bool {
+				first, _ := r.TouchPresence(userID, "phone", time.Minute)
+				again, _ := r.TouchPresence(userID, "phone", time.Minute)
+				second, _ := r.TouchPresence(userID, "laptop", time.Minute)
+				return []bool{first, again, second}
+			},
+			want:       []bool{true, false, false},
+			wantOnline: true,
+		},
+		{
+			name: "user offline after last session",
+			run: func(r *RedisRepository, userID uint) []bool {
+				r.TouchPresence(userID, "phone", time.Minute)
+				r.TouchPresence(userID, "laptop", time.Minute)
+				first, _ := r.RemovePresence(userID, "phone")
+				last, _ := r.RemovePresence(userID, "laptop")
+				return []bool{first, last}
+			},
+			want:       []bool{false, true},
+			wantOnline: false,
+		},
+		{
+			name: "expired session is not counted",
+			run: func(r *RedisRepository, userID uint) []bool {
+				r.TouchPresence(userID, "phone", time.Millisecond)
+				time.Sleep(10 * time.Millisecond)
+				online, _ := r.TouchPresence(userID, "laptop", time.Minute)
+				offline, _ := r.RemovePresence(userID, "laptop")
+				return []bool{online, offline}
+			},
+			want:       []bool{true, true},
+			wantOnline: false,
+		},
+	}
+
+	r := newTestRepository(t)
+	base := uint(time.Now().UnixNano() % 1_000_000_000)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := base + uint(i)
+			got := tt.run(r, userID)
+			for j := range tt.want {
+				if got[j] != tt.want[j] {
+					t.Errorf("step %d = %v, want %v", j+1, got[j], tt.want[j])
+				}
+			}
+
+			presence, err := r.GetPresence([]uint{userID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(presence) != 1 || presence[0].UserID != userID {
+				t.Fatalf("presence = %v, want one of user %d", presence, userID)
+			}
+			if presence[0].Online != tt.wantOnline {
+				t.Errorf("online = %v, want %v", presence[0].Online, tt.wantOnline)
+			}
+			if presence[0].LastSeenAt == nil {
+				t.Error("last seen is not set")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package redispkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sibhellyx/Messenger/internal/models/payload"
+)
+
+func wsTicketKey(ticket string) string {
+	return fmt.Sprintf("ws_ticket:%s", ticket)
+}
+
+// ticket for websocket connect, bound to session
+func (r *RedisRepository) SaveWsTicket(ticket string, p payload.JwtPayload, ttl time.Duration) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ws ticket: %w", err)
+	}
+	return r.client.client.Set(r.ctx, wsTicketKey(ticket), data, ttl).Err()
+}
+
+// ticket is deleted on first use
+func (r *RedisRepository) ConsumeWsTicket(ticket string) (payload.JwtPayload, error) {
+	data, err := r.client.client.GetDel(r.ctx, wsTicketKey(ticket)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return payload.JwtPayload{}, fmt.Errorf("ws ticket not found or already used")
+		}
+		return payload.JwtPayload{}, fmt.Errorf("failed to get ws ticket: %w", err)
+	}
+
+	var p payload.JwtPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return payload.JwtPayload{}, fmt.Errorf("failed to unmarshal ws ticket: %w", err)
+	}
+	return p, nil
+}
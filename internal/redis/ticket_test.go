@@ -0,0 +1,115 @@
+package redispkg
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sibhellyx/Messenger/internal/config"
+	"github.com/sibhellyx/Messenger/internal/models/payload"
+)
+
+// repository on redis from TEST_REDIS_ADDR, test is skipped without it
+func newTestRepository(t *testing.T) *RedisRepository {
+	t.Helper()
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid TEST_REDIS_ADDR: %v", err)
+	}
+
+	client := NewClient(config.RedisConfig{Host: host, Port: port})
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return NewRedisRepository(client)
+}
+
+func TestWsTicketIsSingleUse(t *testing.T) {
+	r := newTestRepository(t)
+	p := payload.JwtPayload{UserId: "1", Uuid: uuid.NewString()}
+
+	tests := []struct {
+		name    string
+		ticket  string
+		ttl     time.Duration // ticket is saved when set
+		want    payload.JwtPayload
+		wantErr bool
+	}{
+		{name: "saved ticket", ticket: uuid.NewString(), ttl: time.Minute, want: p},
+		{name: "short lived ticket", ticket: uuid.NewString(), ttl: time.Second, want: p},
+		{name: "unknown ticket", ticket: uuid.NewString(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.ttl > 0 {
+				if err := r.SaveWsTicket(tt.ticket, p, tt.ttl); err != nil {
+					t.Fatal(err)
+				}
+				// expiry itself is done by redis, unused ticket must not live longer than ttl
+				ttl, err := r.client.client.TTL(r.ctx, wsTicketKey(tt.ticket)).Result()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ttl <= 0 || ttl > tt.ttl {
+					t.Errorf("ticket ttl = %v, want up to %v", ttl, tt.ttl)
+				}
+			}
+
+			got, err := r.ConsumeWsTicket(tt.ticket)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ConsumeWsTicket() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ConsumeWsTicket() = %v, want %v", got, tt.want)
+			}
+
+			if _, err := r.ConsumeWsTicket(tt.ticket); err == nil {
+				t.Error("ticket used twice")
+			}
+		})
+	}
+}
+
+func TestWsTicketConsumedOnceConcurrently(t *testing.T) {
+	r := newTestRepository(t)
+	ticket := uuid.NewString()
+	if err := r.SaveWsTicket(ticket, payload.JwtPayload{UserId: "1", Uuid: uuid.NewString()}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	used := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.ConsumeWsTicket(ticket); err == nil {
+				mu.Lock()
+				used++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if used != 1 {
+		t.Errorf("ticket used %d times, want 1", used)
+	}
+}
@@ -1,6 +1,8 @@
 package authservice
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -56,6 +58,11 @@ type RedisRepositoryInterface interface {
 	IncrementLoginAttempts(userID uint) error
 	SaveUserRegistration(userID uint, tgChatId int64) error
 	GetUserRegistration(userID uint) (int64, error)
+	SaveWsTicket(ticket string, payload payload.JwtPayload, ttl time.Duration) error
+}
+
+type WsServiceInterface interface {
+	CloseSession(uuid string)
 }
 
 type AuthService struct {
@@ -64,10 +71,12 @@ type AuthService struct {
 	hasher       HasherInterface
 	tokenManager TokenManagerInterface
 	bot          BotServiceInterface
+	ws           WsServiceInterface
 	redis        RedisRepositoryInterface
 
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
+	wsTicketTTL     time.Duration
 	activeSessions  int
 }
 
@@ -76,7 +85,7 @@ func NewAuthService(
 	hasher HasherInterface,
 	manager TokenManagerInterface,
 	redis RedisRepositoryInterface,
-	accessTokenTTL, refreshTokenTTL time.Duration,
+	accessTokenTTL, refreshTokenTTL, wsTicketTTL time.Duration,
 	activeSessions int,
 ) *AuthService {
 	return &AuthService{
@@ -86,6 +95,7 @@ func NewAuthService(
 		redis:           redis,
 		accessTokenTTL:  accessTokenTTL,
 		refreshTokenTTL: refreshTokenTTL,
+		wsTicketTTL:     wsTicketTTL,
 		activeSessions:  activeSessions,
 	}
 }
@@ -94,6 +104,10 @@ func (s *AuthService) SetBotService(bot BotServiceInterface) {
 	s.bot = bot
 }
 
+func (s *AuthService) SetWsService(ws WsServiceInterface) {
+	s.ws = ws
+}
+
 // register user service layer
 func (s *AuthService) RegisterUser(r request.RegisterRequest) (string, error) {
 	slog.Debug("service register started")
@@ -379,5 +393,32 @@ func (s *AuthService) Logout(userId, uuid string) error {
 		slog.Error("failed logout", "error", err.Error())
 		return errors.New("failed logout")
 	}
+	// session deleted, his socket can't stay open
+	if s.ws != nil {
+		s.ws.CloseSession(uuid)
+	}
 	return nil
 }
+
+// single use ticket for websocket connect, browsers can't send authorization header on upgrade
+func (s *AuthService) CreateWsTicket(userId, uuid string) (response.WsTicket, error) {
+	slog.Debug("creating ws ticket", "uuid", uuid, "user_id", userId)
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		slog.Error("failed generate ws ticket", "error", err.Error())
+		return response.WsTicket{}, errors.New("failed create ticket")
+	}
+	ticket := hex.EncodeToString(buf)
+
+	err := s.redis.SaveWsTicket(ticket, payload.JwtPayload{UserId: userId, Uuid: uuid}, s.wsTicketTTL)
+	if err != nil {
+		slog.Error("failed save ws ticket", "error", err.Error())
+		return response.WsTicket{}, errors.New("failed create ticket")
+	}
+
+	return response.WsTicket{
+		Ticket:    ticket,
+		ExpiresIn: int(s.wsTicketTTL.Seconds()),
+	}, nil
+}
@@ -1,7 +1,6 @@
 package chatservice
 
 import (
-	"encoding/json"
 	"errors"
 	"log/slog"
 	"strconv"
@@ -11,7 +10,9 @@ import (
 	"github.com/sibhellyx/Messenger/internal/models/chaterrors"
 	"github.com/sibhellyx/Messenger/internal/models/entity"
 	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/response"
 	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	"github.com/sibhellyx/Messenger/internal/ws"
 )
 
 type ChatRepositoryInterface interface {
@@ -31,6 +32,7 @@ type ChatRepositoryInterface interface {
 	UpdateChat(chat *entity.Chat) (*entity.Chat, error)
 	// get chats user
 	GetUserChats(userID uint) ([]*entity.Chat, error)
+	GetUnreadCounts(userID uint) (map[uint]int64, error)
 	// get all chats
 	GetChats() ([]*entity.Chat, error)
 	// geting chats by name searching
@@ -56,10 +58,23 @@ type ChatRepositoryInterface interface {
 	ParticipantIsOwner(userID, chatID uint) bool
 	// check user for exist
 	UserExist(userID uint) bool
+
+	// check message belongs to chat
+	MessageInChat(chatID, messageID uint) bool
+	// pin and unpin message, false if nothing changed
+	PinMessage(pin *entity.ChatPin) (bool, error)
+	UnpinMessage(chatID, messageID uint) (bool, error)
+	// get pinned messages of chat
+	GetPinnedMessages(chatID uint) ([]*response.PinnedMessage, error)
 }
 
 type WsServiceInterface interface {
-	BroadcastMessage(msg []byte) error
+	// send message to connected participants of chat
+	BroadcastMessage(chatID uint, msg []byte) error
+	// keep index of chat members in hub actual
+	AddChatMember(chatID, userID uint)
+	RemoveChatMember(chatID, userID uint)
+	RemoveChat(chatID uint)
 }
 
 type ChatService struct {
@@ -218,12 +233,13 @@ func (s *ChatService) DeleteChat(userID string, req request.ChatRequest) error {
 		Action: wsmsg.ChatDeleted,
 	}
 
-	responseByte, err := json.Marshal(msg)
+	responseByte, err := ws.MarshalEvent(msg)
 	if err != nil {
 		slog.Error("failed to marshal message", "chat_id", chatId, "error", err)
 	}
 
-	s.service.BroadcastMessage(responseByte)
+	s.service.BroadcastMessage(uint(chatId), responseByte)
+	s.service.RemoveChat(uint(chatId))
 
 	slog.Debug("deleting chat completed", "chat_id", chatId)
 	return nil
@@ -263,10 +279,14 @@ func (s *ChatService) UpdateChat(userID string, req request.UpdateChatRequest) (
 		return nil, err
 	}
 
-	// if chat directed not updated data
+	// if chat directed not updated data, only disappearing messages can be set
 	if chat.Type == entity.ChatTypeDirect {
-		slog.Error("cant update direct chat", "chat_id", chatId)
-		return nil, chaterrors.ErrCantUpdaeteDirect
+		if req.MessageTTL == nil {
+			slog.Error("cant update direct chat", "chat_id", chatId)
+			return nil, chaterrors.ErrCantUpdaeteDirect
+		}
+		chat.MessageTTL = *req.MessageTTL
+		return s.repository.UpdateChat(chat)
 	}
 
 	// update chat from req
@@ -280,6 +300,9 @@ func (s *ChatService) UpdateChat(userID string, req request.UpdateChatRequest) (
 		chat.AvatarURL = req.AvatarURL
 	}
 	chat.IsPrivate = req.IsPrivate
+	if req.MessageTTL != nil {
+		chat.MessageTTL = *req.MessageTTL
+	}
 
 	updatedChat, err := s.repository.UpdateChat(chat)
 	if err != nil {
@@ -291,13 +314,30 @@ func (s *ChatService) UpdateChat(userID string, req request.UpdateChatRequest) (
 
 }
 
-func (s *ChatService) GetChatsUser(userID string) ([]*entity.Chat, error) {
+// chats of user with number of unread messages
+func (s *ChatService) GetChatsUser(userID string) ([]*response.UserChat, error) {
 	id, err := strconv.ParseUint(userID, 10, 32)
 	if err != nil {
 		slog.Error("failed parse user_id to uint", "user_id", userID)
 		return nil, chaterrors.ErrInvalidUser
 	}
-	return s.repository.GetUserChats(uint(id))
+	chats, err := s.repository.GetUserChats(uint(id))
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.repository.GetUnreadCounts(uint(id))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*response.UserChat, 0, len(chats))
+	for _, chat := range chats {
+		result = append(result, &response.UserChat{
+			Chat:        chat,
+			UnreadCount: unread[chat.ID],
+		})
+	}
+	return result, nil
 }
 
 func (s *ChatService) GetChats() ([]*entity.Chat, error) {
@@ -397,6 +437,8 @@ func (s *ChatService) EnterToChat(userID string, req request.ChatRequest) error
 	if err != nil {
 		return err
 	}
+	s.service.AddChatMember(uint(chatId), uint(userId))
+
 	msg := wsmsg.ParticipantMsg{
 		ChatID: uint(chatId),
 		UserID: uint(userId),
@@ -404,12 +446,12 @@ func (s *ChatService) EnterToChat(userID string, req request.ChatRequest) error
 		Action: wsmsg.Entered,
 	}
 
-	responseByte, err := json.Marshal(msg)
+	responseByte, err := ws.MarshalEvent(msg)
 	if err != nil {
 		slog.Error("failed to marshal message", "chat_id", req.Id, "user_id", userId, "error", err)
 	}
 
-	s.service.BroadcastMessage(responseByte)
+	s.service.BroadcastMessage(uint(chatId), responseByte)
 	return nil
 }
 
@@ -484,12 +526,14 @@ func (s *ChatService) RemoveParticipant(userID string, req request.ParticipantRe
 		Action: wsmsg.Removed,
 	}
 
-	responseByte, err := json.Marshal(msg)
+	responseByte, err := ws.MarshalEvent(msg)
 	if err != nil {
 		slog.Error("failed to marshal message", "chat_id", chatId, "user_id", userId, "error", err)
 	}
 
-	s.service.BroadcastMessage(responseByte)
+	// removed user also gets event, after that he leaves index
+	s.service.BroadcastMessage(uint(chatId), responseByte)
+	s.service.RemoveChatMember(uint(chatId), uint(participantId))
 	return nil
 }
 
@@ -530,17 +574,19 @@ func (s *ChatService) addParticipant(chatID, userID uint, role entity.Participan
 	if err != nil {
 		return err
 	}
+	s.service.AddChatMember(chatID, userID)
+
 	msg := wsmsg.ParticipantMsg{
 		ChatID: uint(chatID),
 		UserID: uint(userID),
 		Type:   "participant",
 		Action: wsmsg.Add,
 	}
-	responseByte, err := json.Marshal(msg)
+	responseByte, err := ws.MarshalEvent(msg)
 	if err != nil {
 		slog.Error("failed to marshal message", "chat_id", chatID, "user_id", userID, "error", err)
 	}
-	s.service.BroadcastMessage(responseByte)
+	s.service.BroadcastMessage(chatID, responseByte)
 	return nil
 }
 
@@ -634,12 +680,13 @@ func (s *ChatService) LeaveFromChat(chatID string, userID string) error {
 		Action: wsmsg.Leaved,
 	}
 
-	responseByte, err := json.Marshal(msg)
+	responseByte, err := ws.MarshalEvent(msg)
 	if err != nil {
 		slog.Error("failed to marshal message", "chat_id", chatId, "user_id", userId, "error", err)
 	}
 
-	s.service.BroadcastMessage(responseByte)
+	s.service.BroadcastMessage(uint(chatId), responseByte)
+	s.service.RemoveChatMember(uint(chatId), uint(userId))
 
 	return nil
 }
@@ -708,3 +755,103 @@ func (s *ChatService) UpdateParticipant(userID string, req request.ParticipantUp
 	userUpdate.Role = role
 	return s.repository.UpdateParticipant(userUpdate)
 }
+
+// admin or owner pin message of chat, pinning twice changes nothing
+func (s *ChatService) PinMessage(userID string, req request.PinRequest) error {
+	return s.changePin(userID, req, true)
+}
+
+func (s *ChatService) UnpinMessage(userID string, req request.PinRequest) error {
+	return s.changePin(userID, req, false)
+}
+
+func (s *ChatService) changePin(userID string, req request.PinRequest, pin bool) error {
+	slog.Debug("changing pin", "chat_id", req.Id, "message_id", req.MessageID, "pin", pin)
+
+	err := req.Validate()
+	if err != nil {
+		slog.Error("failed validate request", "error", err)
+		return err
+	}
+
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return chaterrors.ErrInvalidUser
+	}
+	chatId, err := strconv.ParseUint(req.Id, 10, 32)
+	if err != nil {
+		slog.Error("failed parse chat_id to uint", "chat_id", req.Id)
+		return chaterrors.ErrInvalidChat
+	}
+
+	can, err := s.repository.UserCanChange(uint(id), uint(chatId))
+	if err != nil {
+		slog.Error("failed get participant info", "error", err)
+		return err
+	}
+	if !can {
+		return chaterrors.ErrNotPermission
+	}
+
+	var changed bool
+	if pin {
+		if !s.repository.MessageInChat(uint(chatId), req.MessageID) {
+			slog.Error("message not found in chat", "chat_id", chatId, "message_id", req.MessageID)
+			return chaterrors.ErrMessageNotFound
+		}
+		changed, err = s.repository.PinMessage(&entity.ChatPin{
+			ChatID:    uint(chatId),
+			MessageID: req.MessageID,
+			PinnedBy:  uint(id),
+		})
+	} else {
+		changed, err = s.repository.UnpinMessage(uint(chatId), req.MessageID)
+	}
+	if err != nil {
+		return err
+	}
+	if !changed {
+		slog.Debug("pin not changed", "chat_id", chatId, "message_id", req.MessageID, "pin", pin)
+		return nil
+	}
+
+	msg := wsmsg.PinMsg{
+		Type:      "message_unpinned",
+		ChatID:    uint(chatId),
+		MessageID: req.MessageID,
+		UserID:    uint(id),
+	}
+	if pin {
+		msg.Type = "message_pinned"
+	}
+
+	responseByte, err := ws.MarshalEvent(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "chat_id", chatId, "user_id", id, "error", err)
+	}
+
+	s.service.BroadcastMessage(uint(chatId), responseByte)
+
+	slog.Debug("pin changed successfully", "chat_id", chatId, "message_id", req.MessageID, "pin", pin)
+	return nil
+}
+
+func (s *ChatService) GetPinnedMessages(userID, chatID string) ([]*response.PinnedMessage, error) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, chaterrors.ErrInvalidUser
+	}
+	chatId, err := strconv.ParseUint(chatID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse chat_id to uint", "chat_id", chatID)
+		return nil, chaterrors.ErrInvalidChat
+	}
+
+	if !s.repository.ParticipantExist(uint(id), uint(chatId)) {
+		return nil, chaterrors.ErrNotParticipant
+	}
+
+	return s.repository.GetPinnedMessages(uint(chatId))
+}
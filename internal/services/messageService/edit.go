@@ -0,0 +1,171 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/kafka"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+// author can edit message during edit window, previous version is kept
+func (s *MessageService) EditMessage(ctx context.Context, userID string, req request.EditMessage) (*entity.Message, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, errors.New("failed parse user_id")
+	}
+
+	message, err := s.repo.GetMessageByID(ctx, req.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Expired() {
+		return nil, errors.New("failed get message")
+	}
+	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), message.ChatID)
+	if err != nil || participant == nil {
+		slog.Error("failed get participant", "chat_id", message.ChatID, "user_id", id, "err", err)
+		return nil, errors.New("this user not participant of this chat")
+	}
+	if message.UserID != uint(id) {
+		slog.Error("permission denied, only author can edit message", "message_id", message.ID, "user_id", id)
+		return nil, errors.New("permission denied, only author can edit message")
+	}
+	if message.Type == entity.MessageTypeSystem {
+		return nil, errors.New("system message can't be edited")
+	}
+	if time.Since(message.CreatedAt) > s.editWindow {
+		slog.Error("edit window expired", "message_id", message.ID, "created_at", message.CreatedAt)
+		return nil, errors.New("message can't be edited anymore")
+	}
+	if err := entity.ValidateEntities(req.Content, req.Entities); err != nil {
+		return nil, err
+	}
+	if message.Content == req.Content && reflect.DeepEqual(message.Entities, req.Entities) {
+		return message, nil
+	}
+
+	if err := s.repo.EditMessage(ctx, message, req.Content, req.Entities, uint(id)); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("chat_%d", message.ChatID)
+	err = s.producer.SendEventWithRetry(ctx, key, kafka.EventMessageEdited, message, 5)
+	if err != nil {
+		slog.Error("error send message to Kafka", "chat_id", message.ChatID, "message_id", message.ID, "err", err)
+		return nil, errors.New("failed send message to Kafka")
+	}
+
+	slog.Info("Message edited", "message_id", message.ID, "chat_id", message.ChatID, "user_id", id)
+	return message, nil
+}
+
+// author or admin and owner of chat delete message for everyone
+func (s *MessageService) DeleteMessage(ctx context.Context, userID string, req request.DeleteMessage) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return errors.New("failed parse user_id")
+	}
+
+	message, err := s.repo.GetMessageByID(ctx, req.MessageID)
+	if err != nil {
+		return err
+	}
+	if message.Expired() {
+		return errors.New("failed get message")
+	}
+	// author who left chat can't delete his messages too
+	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), message.ChatID)
+	if err != nil || participant == nil {
+		slog.Error("failed get participant", "chat_id", message.ChatID, "user_id", id, "err", err)
+		return errors.New("this user not participant of this chat")
+	}
+	if message.UserID != uint(id) && participant.Role != entity.RoleOwner && participant.Role != entity.RoleAdmin {
+		slog.Error("permission denied, member can't delete message of other user", "message_id", message.ID, "user_id", id)
+		return errors.New("permission denied, only author, admin or owner can delete message")
+	}
+
+	if err := s.repo.DeleteMessage(ctx, message, uint(id)); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("chat_%d", message.ChatID)
+	err = s.producer.SendEventWithRetry(ctx, key, kafka.EventMessageDeleted, message, 5)
+	if err != nil {
+		slog.Error("error send message to Kafka", "chat_id", message.ChatID, "message_id", message.ID, "err", err)
+		return errors.New("failed send message to Kafka")
+	}
+
+	slog.Info("Message deleted", "message_id", message.ID, "chat_id", message.ChatID, "deleted_by", id)
+	return nil
+}
+
+func (s *MessageService) processEditedMessage(ctx context.Context, message entity.Message) error {
+	// redelivered old edit must not overwrite newer one on clients
+	current, err := s.repo.GetMessageByID(ctx, message.ID)
+	if err != nil {
+		slog.Warn("message of event not found, skipping", "message_id", message.ID, "err", err)
+		return nil
+	}
+	if current.EditedAt != nil && message.EditedAt != nil && current.EditedAt.After(*message.EditedAt) {
+		slog.Info("newer edit exists, skipping", "message_id", message.ID)
+		return nil
+	}
+
+	wsMessage := map[string]interface{}{
+		"type":       kafka.EventMessageEdited,
+		"message_id": message.ID,
+		"chat_id":    message.ChatID,
+		"user_id":    message.UserID,
+		"content":    message.Content,
+		"entities":   message.Entities,
+		"edited_at":  message.EditedAt,
+	}
+	return s.broadcast(message, wsMessage)
+}
+
+func (s *MessageService) processDeletedMessage(message entity.Message) error {
+	wsMessage := map[string]interface{}{
+		"type":       kafka.EventMessageDeleted,
+		"message_id": message.ID,
+		"chat_id":    message.ChatID,
+		"user_id":    message.UserID,
+		"deleted_by": message.DeletedBy,
+	}
+	return s.broadcast(message, wsMessage)
+}
+
+func (s *MessageService) broadcast(message entity.Message, wsMessage map[string]interface{}) error {
+	messageBytes, err := ws.MarshalEvent(wsMessage)
+	if err != nil {
+		slog.Error("failed to marshal WebSocket message", "err", err, "message", message)
+		return errors.New("failed marshal message json to byte")
+	}
+
+	if err := s.wsService.BroadcastMessage(message.ChatID, messageBytes); err != nil {
+		slog.Warn("Failed to broadcast WebSocket message",
+			"error", err,
+			"chat_id", message.ChatID)
+	}
+
+	slog.Info("Message event processed successfully",
+		"type", wsMessage["type"],
+		"message_id", message.ID,
+		"chat_id", message.ChatID)
+	return nil
+}
@@ -0,0 +1,130 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/config"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"gorm.io/gorm"
+)
+
+// returned by writes of fake repository, so service stops before kafka
+var errWritten = errors.New("written")
+
+// only reading and changing of messages and claiming of scheduled ones are known,
+// other methods are not used
+type fakeMessageRepo struct {
+	MessageRepositoryInterface
+	messages map[uint]*entity.Message
+	due      []*entity.ScheduledMessage
+	finished map[uint]int // scheduled message -> max attempts it was finished with
+}
+
+func (r *fakeMessageRepo) GetMessageByID(ctx context.Context, id uint) (*entity.Message, error) {
+	message, ok := r.messages[id]
+	if !ok {
+		return nil, errors.New("message not found")
+	}
+	copied := *message
+	return &copied, nil
+}
+
+func (r *fakeMessageRepo) EditMessage(ctx context.Context, message *entity.Message, content string, entities []entity.MessageEntity, editorID uint) error {
+	return errWritten
+}
+
+func (r *fakeMessageRepo) DeleteMessage(ctx context.Context, message *entity.Message, deletedBy uint) error {
+	return errWritten
+}
+
+func (r *fakeMessageRepo) ClaimDueScheduled(ctx context.Context, limit int, claimedBefore time.Time) ([]*entity.ScheduledMessage, error) {
+	return r.due, nil
+}
+
+func (r *fakeMessageRepo) FinishScheduled(ctx context.Context, message *entity.ScheduledMessage, sendErr error, maxAttempts int) error {
+	if r.finished == nil {
+		r.finished = make(map[uint]int)
+	}
+	r.finished[message.ID] = maxAttempts
+	return nil
+}
+
+// only participants of chat are known, other methods are not used
+type fakeChatRepo struct {
+	ChatRepositoryInterface
+	chatErr      error                           // returned by lookup of chat
+	roles        map[uint]entity.ParticipantRole // user -> role in chat
+	participants []*entity.ChatParticipant
+}
+
+func (r *fakeChatRepo) GetChatById(chatID uint) (*entity.Chat, error) {
+	if r.chatErr != nil {
+		return nil, r.chatErr
+	}
+	return &entity.Chat{Model: gorm.Model{ID: chatID}, Type: entity.ChatTypeGroup}, nil
+}
+
+func (r *fakeChatRepo) ParticipantExist(userID, chatID uint) bool {
+	_, ok := r.roles[userID]
+	return ok
+}
+
+func (r *fakeChatRepo) GetParticipantByUserIdAndChatId(userID, chatID uint) (*entity.ChatParticipant, error) {
+	role, ok := r.roles[userID]
+	if !ok {
+		return nil, errors.New("participant not found")
+	}
+	return &entity.ChatParticipant{UserID: userID, ChatID: chatID, Role: role}, nil
+}
+
+func TestEditAndDeleteMessagePermissions(t *testing.T) {
+	// 1 is author, 4 has left the chat, 5 wrote message and left the chat
+	chats := &fakeChatRepo{roles: map[uint]entity.ParticipantRole{
+		1: entity.RoleMember,
+		2: entity.RoleMember,
+		3: entity.RoleAdmin,
+	}}
+	past := time.Now().Add(-time.Minute)
+	repo := &fakeMessageRepo{messages: map[uint]*entity.Message{
+		1: {Model: gorm.Model{ID: 1, CreatedAt: time.Now()}, ChatID: 10, UserID: 1, Content: "hi"},
+		2: {Model: gorm.Model{ID: 2, CreatedAt: time.Now().Add(-time.Hour)}, ChatID: 10, UserID: 1, Content: "old"},
+		3: {Model: gorm.Model{ID: 3, CreatedAt: time.Now()}, ChatID: 10, UserID: 1, Content: "gone", ExpiresAt: &past},
+		4: {Model: gorm.Model{ID: 4, CreatedAt: time.Now()}, ChatID: 10, UserID: 5, Content: "left"},
+	}}
+	s := NewMessageService(nil, nil, repo, chats, nil, config.MessageConfig{EditWindow: 10 * time.Minute})
+
+	tests := []struct {
+		name       string
+		userID     string
+		messageID  uint
+		wantEdit   bool
+		wantDelete bool
+	}{
+		{name: "author", userID: "1", messageID: 1, wantEdit: true, wantDelete: true},
+		{name: "author after edit window", userID: "1", messageID: 2, wantDelete: true},
+		{name: "other member", userID: "2", messageID: 1},
+		{name: "admin", userID: "3", messageID: 1, wantDelete: true},
+		{name: "user who left chat", userID: "4", messageID: 1},
+		{name: "author who left chat", userID: "5", messageID: 4},
+		{name: "expired message", userID: "1", messageID: 3},
+		{name: "expired message by admin", userID: "3", messageID: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.EditMessage(context.Background(), tt.userID, request.EditMessage{MessageID: tt.messageID, Content: "edited"})
+			if edited := errors.Is(err, errWritten); edited != tt.wantEdit {
+				t.Errorf("edited = %v (%v), want %v", edited, err, tt.wantEdit)
+			}
+
+			err = s.DeleteMessage(context.Background(), tt.userID, request.DeleteMessage{MessageID: tt.messageID})
+			if deleted := errors.Is(err, errWritten); deleted != tt.wantDelete {
+				t.Errorf("deleted = %v (%v), want %v", deleted, err, tt.wantDelete)
+			}
+		})
+	}
+}
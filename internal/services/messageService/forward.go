@@ -0,0 +1,94 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+)
+
+// copy messages from chats of user to another chat, copies are sent as new messages
+func (s *MessageService) ForwardMessages(ctx context.Context, userID string, req request.ForwardMessages) ([]*entity.Message, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, errors.New("failed parse user_id")
+	}
+	chatID, err := strconv.ParseUint(req.ChatID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse chat_id to uint", "chat_id", req.ChatID)
+		return nil, errors.New("failed parse chat_id")
+	}
+
+	chat, err := s.chatRepo.GetChatById(uint(chatID))
+	if err != nil {
+		slog.Error("failed get chat", "chat_id", chatID, "err", err)
+		return nil, errors.New("failed get chat")
+	}
+	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), uint(chatID))
+	if err != nil || participant == nil {
+		slog.Error("failed get participant", "chat_id", chatID, "user_id", id, "err", err)
+		return nil, errors.New("this user not participant of this chat")
+	}
+	if err := checkCanPost(chat, participant); err != nil {
+		return nil, err
+	}
+
+	originals, err := s.repo.GetMessagesByIDs(ctx, req.MessageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// check everything before sending anything
+	sourceChats := make(map[uint]bool)
+	forwarded := make([]*entity.Message, 0, len(req.MessageIDs))
+	for _, messageID := range req.MessageIDs {
+		original, ok := originals[messageID]
+		if !ok || original.DeletedAt.Valid || original.Expired() {
+			slog.Error("message to forward not found", "message_id", messageID)
+			return nil, fmt.Errorf("message %d not found", messageID)
+		}
+		if original.Type == entity.MessageTypeSystem {
+			return nil, errors.New("system message can't be forwarded")
+		}
+		if _, checked := sourceChats[original.ChatID]; !checked {
+			sourceChats[original.ChatID] = s.chatRepo.ParticipantExist(uint(id), original.ChatID)
+		}
+		if !sourceChats[original.ChatID] {
+			slog.Error("user not participant of source chat", "chat_id", original.ChatID, "user_id", id)
+			return nil, fmt.Errorf("message %d not found", messageID)
+		}
+
+		message := original.Forward(uint(chatID), uint(id))
+		message.ExpiresAt = expiresAt(chat)
+		forwarded = append(forwarded, &message)
+	}
+
+	key := fmt.Sprintf("chat_%d", chatID)
+	for _, message := range forwarded {
+		if err := s.repo.CreateMessage(ctx, message); err != nil {
+			slog.Error("error create message", "chat_id", message.ChatID, "user_id", message.UserID, "err", err)
+			return nil, errors.New("failed create message")
+		}
+
+		err = s.producer.SendJSONWithRetry(ctx, key, message, 5)
+		if err != nil {
+			slog.Error("error send message to Kafka", "chat_id", message.ChatID, "user_id", message.UserID, "err", err)
+			return nil, errors.New("failed send message to Kafka")
+		}
+	}
+
+	slog.Info("Messages forwarded successfully",
+		"chat_id", chatID,
+		"user_id", id,
+		"count", len(forwarded))
+
+	return forwarded, nil
+}
@@ -0,0 +1,117 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+const maxMentionsLimit = 100
+
+// save mentions of participants in text message, author can't mention himself
+func (s *MessageService) saveMentions(ctx context.Context, message *entity.Message) error {
+	if message.Type != entity.MessageTypeText {
+		return nil
+	}
+	names := entity.ParseMentions(message.Content)
+	if len(names) == 0 {
+		return nil
+	}
+
+	users, err := s.userRepo.GetChatParticipantsByTgnames(message.ChatID, names)
+	if err != nil {
+		return err
+	}
+
+	mentions := make([]entity.MessageMention, 0, len(users))
+	for _, user := range users {
+		if user.ID == message.UserID {
+			continue
+		}
+		mentions = append(mentions, entity.MessageMention{
+			MessageID: message.ID,
+			UserID:    user.ID,
+			ChatID:    message.ChatID,
+		})
+		message.Mentions = append(message.Mentions, user.ID)
+	}
+	return s.repo.CreateMentions(ctx, mentions)
+}
+
+// notification of new message for participants who did not mute chat,
+// mentioned users get mention instead of it even in muted chat
+func (s *MessageService) notifyParticipants(message *entity.Message) {
+	participants, err := s.chatRepo.GetChatParticipants(message.ChatID, nil)
+	if err != nil {
+		slog.Error("failed get participants to notify", "chat_id", message.ChatID, "message_id", message.ID, "error", err)
+		return
+	}
+
+	mentioned := make(map[uint]bool, len(message.Mentions))
+	for _, userID := range message.Mentions {
+		mentioned[userID] = true
+	}
+	var notified, mentions []uint
+	for _, participant := range participants {
+		switch {
+		case participant.UserID == message.UserID:
+		case mentioned[participant.UserID]:
+			mentions = append(mentions, participant.UserID)
+		case !participant.IsMuted && participant.NotificationsEnabled:
+			notified = append(notified, participant.UserID)
+		}
+	}
+
+	content := message.Preview().Content
+	s.notifyUsers(notified, message, wsmsg.NotificationMsg{
+		Type:      "notification",
+		ChatID:    message.ChatID,
+		MessageID: message.ID,
+		UserID:    message.UserID,
+		Content:   content,
+	})
+	s.notifyUsers(mentions, message, wsmsg.MentionMsg{
+		Type:      "mention",
+		ChatID:    message.ChatID,
+		MessageID: message.ID,
+		UserID:    message.UserID,
+		Content:   content,
+	})
+}
+
+func (s *MessageService) notifyUsers(userIDs []uint, message *entity.Message, msg interface{}) {
+	if len(userIDs) == 0 {
+		return
+	}
+	msgBytes, err := ws.MarshalEvent(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "message_id", message.ID, "error", err)
+		return
+	}
+	if err := s.wsService.NotifyUsers(userIDs, msgBytes); err != nil {
+		slog.Warn("Failed to send notification", "error", err, "message_id", message.ID, "user_ids", userIDs)
+	}
+}
+
+// unread messages where user is mentioned, chatID is optional
+func (s *MessageService) GetMentions(ctx context.Context, userID, chatID string) ([]*entity.Message, error) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, errors.New("failed parse user_id")
+	}
+	var chatId uint64
+	if chatID != "" {
+		chatId, err = strconv.ParseUint(chatID, 10, 32)
+		if err != nil {
+			slog.Error("failed parse chat_id to uint", "chat_id", chatID)
+			return nil, errors.New("failed parse chat_id")
+		}
+	}
+	return s.repo.GetUnreadMentions(ctx, uint(id), uint(chatId), maxMentionsLimit)
+}
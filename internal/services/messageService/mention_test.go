@@ -0,0 +1,113 @@
+package messageservice
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/backplane"
+	"github.com/sibhellyx/Messenger/internal/config"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	wsservice "github.com/sibhellyx/Messenger/internal/services/wsService"
+	"github.com/sibhellyx/Messenger/internal/ws"
+	"gorm.io/gorm"
+)
+
+func (r *fakeChatRepo) GetChatParticipants(chatID uint, since *time.Time) ([]*entity.ChatParticipant, error) {
+	return r.participants, nil
+}
+
+// every event gets next seq, events are not read back
+type fakeEvents struct {
+	mu  sync.Mutex
+	seq uint64
+}
+
+func (e *fakeEvents) AppendEvents(userIDs []uint, data []byte, messageID uint, maxLen int64, ttl time.Duration) (map[uint]uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.seq++
+	result := make(map[uint]uint64, len(userIDs))
+	for _, id := range userIDs {
+		result[id] = e.seq
+	}
+	return result, nil
+}
+
+func (e *fakeEvents) GetEventsSince(userID uint, since uint64) ([]wsmsg.Event, uint64, bool, error) {
+	return nil, 0, true, nil
+}
+
+// receivers of published events by event type
+func recordByType(t *testing.T, bp backplane.Backplane) func() map[string][]string {
+	t.Helper()
+	var mu sync.Mutex
+	result := make(map[string][]string)
+	err := bp.Subscribe(func(event backplane.Event) {
+		var data struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(event.Data, &data)
+		mu.Lock()
+		defer mu.Unlock()
+		result[data.Type] = append(result[data.Type], event.UserIDs...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() map[string][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, ids := range result {
+			sort.Strings(ids)
+		}
+		return result
+	}
+}
+
+func TestNotifyParticipants(t *testing.T) {
+	// 1 is author, 3 muted chat, 4 disabled notifications
+	participants := []*entity.ChatParticipant{
+		{UserID: 1, NotificationsEnabled: true},
+		{UserID: 2, NotificationsEnabled: true},
+		{UserID: 3, IsMuted: true, NotificationsEnabled: true},
+		{UserID: 4, NotificationsEnabled: false},
+		{UserID: 5, NotificationsEnabled: true},
+	}
+
+	tests := []struct {
+		name             string
+		mentions         []uint
+		wantNotification []string
+		wantMention      []string
+	}{
+		{name: "without mentions", wantNotification: []string{"2", "5"}},
+		{name: "mention replaces notification", mentions: []uint{5}, wantNotification: []string{"2"}, wantMention: []string{"5"}},
+		{name: "mention bypasses mute", mentions: []uint{3, 4}, wantNotification: []string{"2", "5"}, wantMention: []string{"3", "4"}},
+		{name: "author mentioning himself", mentions: []uint{1}, wantNotification: []string{"2", "5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := backplane.NewMemoryBackplane()
+			received := recordByType(t, bp)
+			conf := config.WsConfig{ReplaySize: 100, ReplayTTL: time.Hour, SendBuffer: 16}
+			wsService := wsservice.NewWsService(ws.NewHub(conf), bp, nil, &fakeEvents{}, conf)
+			s := NewMessageService(wsService, nil, nil, &fakeChatRepo{participants: participants}, nil, config.MessageConfig{})
+
+			s.notifyParticipants(&entity.Message{Model: gorm.Model{ID: 7}, ChatID: 10, UserID: 1, Content: "hi", Mentions: tt.mentions})
+
+			got := received()
+			if !slices.Equal(got["notification"], tt.wantNotification) {
+				t.Errorf("notified = %v, want %v", got["notification"], tt.wantNotification)
+			}
+			if !slices.Equal(got["mention"], tt.wantMention) {
+				t.Errorf("mentioned = %v, want %v", got["mention"], tt.wantMention)
+			}
+		})
+	}
+}
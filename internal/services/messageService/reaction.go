@@ -0,0 +1,95 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+func (s *MessageService) AddReaction(ctx context.Context, userID string, req request.ReactionRequest) error {
+	return s.changeReaction(ctx, userID, req, true)
+}
+
+func (s *MessageService) RemoveReaction(ctx context.Context, userID string, req request.ReactionRequest) error {
+	return s.changeReaction(ctx, userID, req, false)
+}
+
+// any participant of chat can react, repeated requests change nothing
+func (s *MessageService) changeReaction(ctx context.Context, userID string, req request.ReactionRequest, add bool) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return errors.New("failed parse user_id")
+	}
+
+	message, err := s.repo.GetMessageByID(ctx, req.MessageID)
+	if err != nil {
+		return err
+	}
+	if message.Expired() {
+		return errors.New("failed get message")
+	}
+	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), message.ChatID)
+	if err != nil || participant == nil {
+		slog.Error("failed get participant", "chat_id", message.ChatID, "user_id", id, "err", err)
+		return errors.New("this user not participant of this chat")
+	}
+
+	var changed bool
+	if add {
+		changed, err = s.repo.AddReaction(ctx, &entity.MessageReaction{
+			MessageID: message.ID,
+			UserID:    uint(id),
+			Emoji:     req.Emoji,
+		})
+	} else {
+		changed, err = s.repo.RemoveReaction(ctx, message.ID, uint(id), req.Emoji)
+	}
+	if err != nil {
+		return err
+	}
+	if !changed {
+		slog.Debug("reaction not changed", "message_id", message.ID, "user_id", id, "emoji", req.Emoji, "added", add)
+		return nil
+	}
+
+	count, err := s.repo.CountReaction(ctx, message.ID, req.Emoji)
+	if err != nil {
+		return err
+	}
+	s.sendReaction(message.ChatID, message.ID, uint(id), req.Emoji, add, count)
+
+	slog.Info("Reaction changed", "message_id", message.ID, "user_id", id, "emoji", req.Emoji, "added", add)
+	return nil
+}
+
+// notify participants of chat about changed reaction
+func (s *MessageService) sendReaction(chatID, messageID, userID uint, emoji string, added bool, count int64) {
+	msg := wsmsg.ReactionMsg{
+		Type:      "reaction_changed",
+		ChatID:    chatID,
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+		Added:     added,
+		Count:     count,
+	}
+	msgBytes, err := ws.MarshalEvent(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "chat_id", chatID, "message_id", messageID, "error", err)
+		return
+	}
+
+	if err := s.wsService.BroadcastMessage(chatID, msgBytes); err != nil {
+		slog.Warn("Failed to broadcast WebSocket message", "error", err, "chat_id", chatID)
+	}
+}
@@ -0,0 +1,60 @@
+package messageservice
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+// expiry of message sent now to chat, nil if chat keeps messages
+func expiresAt(chat *entity.Chat) *time.Time {
+	if chat.MessageTTL <= 0 {
+		return nil
+	}
+	expires := time.Now().Add(time.Duration(chat.MessageTTL) * time.Second)
+	return &expires
+}
+
+// removes expired messages until ctx is done, safe to run on every replica
+func (s *MessageService) StartReaper(ctx context.Context) {
+	ticker := time.NewTicker(s.reaperInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Reaper stopped")
+			return
+		case <-ticker.C:
+			s.reapExpiredMessages(ctx)
+		}
+	}
+}
+
+func (s *MessageService) reapExpiredMessages(ctx context.Context) {
+	removed, err := s.repo.DeleteExpiredMessages(ctx, s.reaperBatch)
+	if err != nil {
+		return
+	}
+
+	for chatID, messageIDs := range removed {
+		msg := wsmsg.ExpiredMsg{
+			Type:       "message_expired",
+			ChatID:     chatID,
+			MessageIDs: messageIDs,
+		}
+		msgBytes, err := ws.MarshalEvent(msg)
+		if err != nil {
+			slog.Error("failed to marshal message", "chat_id", chatID, "error", err)
+			continue
+		}
+		if err := s.wsService.BroadcastMessage(chatID, msgBytes); err != nil {
+			slog.Warn("Failed to broadcast WebSocket message", "error", err, "chat_id", chatID)
+		}
+		slog.Info("Expired messages removed", "chat_id", chatID, "count", len(messageIDs))
+	}
+}
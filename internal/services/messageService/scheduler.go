@@ -0,0 +1,193 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/models/chaterrors"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+)
+
+// message is checked now and again at send time, user may leave chat meanwhile
+func (s *MessageService) ScheduleMessage(ctx context.Context, userID string, req request.ScheduleMessage) (*entity.ScheduledMessage, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if req.SendAt.After(time.Now().Add(s.scheduleMaxAhead)) {
+		slog.Error("sendAt too far in future", "send_at", req.SendAt)
+		return nil, errors.New("sendAt too far in future")
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, errors.New("failed parse user_id")
+	}
+	chatID, err := strconv.ParseUint(req.ChatID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse chat_id to uint", "chat_id", req.ChatID)
+		return nil, errors.New("failed parse chat_id")
+	}
+
+	chat, err := s.chatRepo.GetChatById(uint(chatID))
+	if err != nil {
+		slog.Error("failed get chat", "chat_id", chatID, "err", err)
+		return nil, errors.New("failed get chat")
+	}
+	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), uint(chatID))
+	if err != nil || participant == nil {
+		slog.Error("failed get participant", "chat_id", chatID, "user_id", id, "err", err)
+		return nil, errors.New("this user not participant of this chat")
+	}
+	if err := checkCanPost(chat, participant); err != nil {
+		return nil, err
+	}
+
+	draft := entity.Message{
+		ChatID:   uint(chatID),
+		UserID:   uint(id),
+		Type:     req.Type,
+		Content:  req.Content,
+		Entities: req.Entities,
+		Status:   entity.MessageStatusSent,
+	}
+	if err := draft.Validate(); err != nil {
+		return nil, err
+	}
+
+	scheduled := entity.ScheduledMessage{
+		ChatID:    uint(chatID),
+		UserID:    uint(id),
+		Type:      req.Type,
+		Content:   req.Content,
+		Entities:  req.Entities,
+		SendAt:    req.SendAt,
+		Status:    entity.ScheduledStatusPending,
+		ClientID:  req.ClientID,
+		FileURL:   req.FileURL,
+		FileName:  req.FileName,
+		FileSize:  req.FileSize,
+		MimeType:  req.MimeType,
+		ReplyToID: req.ReplyToID,
+	}
+	if err := s.repo.CreateScheduledMessage(ctx, &scheduled); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Message scheduled", "id", scheduled.ID, "chat_id", chatID, "user_id", id, "send_at", req.SendAt)
+	return &scheduled, nil
+}
+
+func (s *MessageService) GetScheduledMessages(ctx context.Context, userID string) ([]*entity.ScheduledMessage, error) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, errors.New("failed parse user_id")
+	}
+	return s.repo.GetScheduledMessages(ctx, uint(id))
+}
+
+// only pending message can be canceled
+func (s *MessageService) CancelScheduledMessage(ctx context.Context, userID string, req request.CancelScheduled) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return errors.New("failed parse user_id")
+	}
+
+	canceled, err := s.repo.CancelScheduledMessage(ctx, req.ID, uint(id))
+	if err != nil {
+		return err
+	}
+	if !canceled {
+		return errors.New("scheduled message not found")
+	}
+
+	slog.Info("Scheduled message canceled", "id", req.ID, "user_id", id)
+	return nil
+}
+
+// sends due messages until ctx is done, safe to run on every replica
+func (s *MessageService) StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(s.scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Scheduler stopped")
+			return
+		case <-ticker.C:
+			s.sendDueMessages(ctx)
+		}
+	}
+}
+
+// rows are claimed in short transaction and sent outside of it,
+// resend after lost claim is deduplicated by client id
+func (s *MessageService) sendDueMessages(ctx context.Context) {
+	due, err := s.repo.ClaimDueScheduled(ctx, s.scheduleBatch, time.Now().Add(-s.scheduleClaimTimeout))
+	if err != nil {
+		return
+	}
+
+	var sent int
+	for _, scheduled := range due {
+		maxAttempts := s.scheduleMaxAttempts
+		sendErr := s.sendScheduled(ctx, scheduled)
+		if sendErr != nil {
+			slog.Warn("failed send scheduled message", "id", scheduled.ID, "chat_id", scheduled.ChatID, "user_id", scheduled.UserID, "attempt", scheduled.Attempts, "err", sendErr)
+			// retries can't help, so this attempt is the last one
+			if s.scheduledUnsendable(scheduled) {
+				maxAttempts = scheduled.Attempts
+			}
+		}
+		if err := s.repo.FinishScheduled(ctx, scheduled, sendErr, maxAttempts); err != nil {
+			continue
+		}
+		if scheduled.Status == entity.ScheduledStatusSent {
+			sent++
+		}
+	}
+	if len(due) > 0 {
+		slog.Info("Scheduled messages processed", "count", len(due), "sent", sent)
+	}
+}
+
+func (s *MessageService) sendScheduled(ctx context.Context, scheduled *entity.ScheduledMessage) error {
+	// scheduled message is recognized by client id of sent one
+	clientID := scheduled.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("scheduled-%d", scheduled.ID)
+	}
+	// no session of user sent it, so every session gets ack
+	_, err := s.SendMessage(ctx, strconv.FormatUint(uint64(scheduled.UserID), 10), "", request.CreateMessage{
+		ChatID:    strconv.FormatUint(uint64(scheduled.ChatID), 10),
+		Content:   scheduled.Content,
+		Entities:  scheduled.Entities,
+		Type:      scheduled.Type,
+		ReplyToID: scheduled.ReplyToID,
+		FileURL:   scheduled.FileURL,
+		FileName:  scheduled.FileName,
+		FileSize:  scheduled.FileSize,
+		MimeType:  scheduled.MimeType,
+		ClientID:  clientID,
+	})
+	return err
+}
+
+// chat of scheduled message was deleted or sender is not its participant anymore,
+// failed lookup of chat is treated as transient
+func (s *MessageService) scheduledUnsendable(scheduled *entity.ScheduledMessage) bool {
+	if _, err := s.chatRepo.GetChatById(scheduled.ChatID); err != nil {
+		return errors.Is(err, chaterrors.ErrChatNotFound)
+	}
+	return !s.chatRepo.ParticipantExist(scheduled.UserID, scheduled.ChatID)
+}
@@ -0,0 +1,47 @@
+package messageservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sibhellyx/Messenger/internal/config"
+	"github.com/sibhellyx/Messenger/internal/models/chaterrors"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"gorm.io/gorm"
+)
+
+func TestScheduledPermanentFailureIsNotRetried(t *testing.T) {
+	const maxAttempts = 5
+
+	tests := []struct {
+		name            string
+		chatErr         error
+		userID          uint
+		wantMaxAttempts int
+	}{
+		{name: "chat deleted", chatErr: chaterrors.ErrChatNotFound, userID: 1, wantMaxAttempts: 2},
+		{name: "sender left chat", userID: 9, wantMaxAttempts: 2},
+		{name: "chat lookup failed", chatErr: chaterrors.ErrFailedGetChat, userID: 1, wantMaxAttempts: maxAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// second attempt of message, claim has counted it already
+			repo := &fakeMessageRepo{due: []*entity.ScheduledMessage{
+				{Model: gorm.Model{ID: 7}, ChatID: 10, UserID: tt.userID, Content: "later", Type: entity.MessageTypeText, Attempts: 2},
+			}}
+			chats := &fakeChatRepo{chatErr: tt.chatErr, roles: map[uint]entity.ParticipantRole{1: entity.RoleMember}}
+			s := NewMessageService(nil, nil, repo, chats, nil, config.MessageConfig{ScheduleMaxAttempts: maxAttempts})
+
+			s.sendDueMessages(context.Background())
+
+			got, ok := repo.finished[7]
+			if !ok {
+				t.Fatal("scheduled message not finished")
+			}
+			if got != tt.wantMaxAttempts {
+				t.Errorf("finished with max attempts %d, want %d", got, tt.wantMaxAttempts)
+			}
+		})
+	}
+}
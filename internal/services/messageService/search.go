@@ -0,0 +1,33 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/response"
+)
+
+// search only in chats where user is participant
+func (s *MessageService) SearchMessages(ctx context.Context, userID string, req request.SearchMessages) ([]*response.MessageSearchResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, errors.New("failed parse user_id")
+	}
+
+	if req.ChatID != 0 {
+		participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), req.ChatID)
+		if err != nil || participant == nil {
+			slog.Error("failed get participant", "chat_id", req.ChatID, "user_id", id, "err", err)
+			return nil, errors.New("this user not participant of this chat")
+		}
+	}
+
+	return s.repo.SearchMessages(ctx, uint(id), req)
+}
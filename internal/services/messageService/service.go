@@ -2,29 +2,61 @@ package messageservice
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
 	"time"
 
+	"github.com/sibhellyx/Messenger/internal/config"
 	"github.com/sibhellyx/Messenger/internal/kafka"
 	"github.com/sibhellyx/Messenger/internal/models/entity"
 	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/response"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
 	wsservice "github.com/sibhellyx/Messenger/internal/services/wsService"
+	"github.com/sibhellyx/Messenger/internal/ws"
 )
 
 type MessageRepositoryInterface interface {
 	CreateMessage(ctx context.Context, message *entity.Message) error
-	UpdateMessageStatus(ctx context.Context, messageID uint, status entity.MessageStatus) error
+	CreateMessageOnce(ctx context.Context, message *entity.Message) (bool, error)
+	MarkBroadcast(ctx context.Context, messageID uint) error
+	MarkReceiptsRead(ctx context.Context, chatID, readerID, afterID, upToID uint) error
+	GetReceiptCounts(ctx context.Context, messageIDs []uint) (map[uint]*entity.ReceiptCounts, error)
+	DeleteExpiredMessages(ctx context.Context, limit int) (map[uint][]uint, error)
+	CreateMentions(ctx context.Context, mentions []entity.MessageMention) error
+	GetUnreadMentions(ctx context.Context, userID, chatID uint, limit int) ([]*entity.Message, error)
 	GetMessageByID(ctx context.Context, id uint) (*entity.Message, error)
+	EditMessage(ctx context.Context, message *entity.Message, content string, entities []entity.MessageEntity, editorID uint) error
+	DeleteMessage(ctx context.Context, message *entity.Message, deletedBy uint) error
+	MarkMessagesRead(ctx context.Context, chatID, readerID, upToID uint) error
+	AddReaction(ctx context.Context, reaction *entity.MessageReaction) (bool, error)
+	RemoveReaction(ctx context.Context, messageID, userID uint, emoji string) (bool, error)
+	CountReaction(ctx context.Context, messageID uint, emoji string) (int64, error)
+	GetReactions(ctx context.Context, messageIDs []uint, userID uint) (map[uint][]*entity.ReactionCount, error)
+	GetThread(ctx context.Context, parentID uint, page request.MessagesPage) ([]*entity.Message, *uint, error)
+	GetReplyCounts(ctx context.Context, messageIDs []uint) (map[uint]int64, error)
+	GetMessagesByIDs(ctx context.Context, messageIDs []uint) (map[uint]*entity.Message, error)
+	SearchMessages(ctx context.Context, userID uint, req request.SearchMessages) ([]*response.MessageSearchResult, error)
+	CreateScheduledMessage(ctx context.Context, message *entity.ScheduledMessage) error
+	GetScheduledMessages(ctx context.Context, userID uint) ([]*entity.ScheduledMessage, error)
+	CancelScheduledMessage(ctx context.Context, id, userID uint) (bool, error)
+	ClaimDueScheduled(ctx context.Context, limit int, claimedBefore time.Time) ([]*entity.ScheduledMessage, error)
+	FinishScheduled(ctx context.Context, message *entity.ScheduledMessage, sendErr error, maxAttempts int) error
 }
 
 type ChatRepositoryInterface interface {
 	GetChatById(chatID uint) (*entity.Chat, error)
 	GetParticipantByUserIdAndChatId(userID, chatID uint) (*entity.ChatParticipant, error)
-	GetMessagesByChatId(chatId uint, since *time.Time) ([]*entity.Message, error)
+	GetMessagesByChatId(chatId uint, since *time.Time, page request.MessagesPage) ([]*entity.Message, *uint, error)
+	AdvanceLastRead(userID, chatID, messageID uint) (bool, error)
+	ParticipantExist(userID, chatID uint) bool
+	GetChatParticipants(chatID uint, since *time.Time) ([]*entity.ChatParticipant, error)
+}
+
+type UserRepositoryInterface interface {
+	GetChatParticipantsByTgnames(chatID uint, tgnames []string) ([]*entity.User, error)
 }
 
 type MessageService struct {
@@ -33,14 +65,35 @@ type MessageService struct {
 	consumer  *kafka.Consumer
 	repo      MessageRepositoryInterface
 	chatRepo  ChatRepositoryInterface
+	userRepo  UserRepositoryInterface
+
+	editWindow       time.Duration
+	scheduleInterval time.Duration
+	scheduleBatch    int
+	scheduleMaxAhead time.Duration
+	// retries of failed scheduled send and timeout of lost claim
+	scheduleMaxAttempts  int
+	scheduleClaimTimeout time.Duration
+	reaperInterval       time.Duration
+	reaperBatch          int
 }
 
-func NewMessageService(wsService *wsservice.WsService, producer *kafka.Producer, repo MessageRepositoryInterface, chatRepo ChatRepositoryInterface) *MessageService {
+func NewMessageService(wsService *wsservice.WsService, producer *kafka.Producer, repo MessageRepositoryInterface, chatRepo ChatRepositoryInterface, userRepo UserRepositoryInterface, conf config.MessageConfig) *MessageService {
 	return &MessageService{
-		wsService: wsService,
-		producer:  producer,
-		repo:      repo,
-		chatRepo:  chatRepo,
+		wsService:        wsService,
+		producer:         producer,
+		repo:             repo,
+		chatRepo:         chatRepo,
+		userRepo:         userRepo,
+		editWindow:       conf.EditWindow,
+		scheduleInterval: conf.ScheduleInterval,
+		scheduleBatch:    conf.ScheduleBatch,
+		scheduleMaxAhead: conf.ScheduleMaxAhead,
+
+		scheduleMaxAttempts:  conf.ScheduleMaxAttempts,
+		scheduleClaimTimeout: conf.ScheduleClaimTimeout,
+		reaperInterval:       conf.ReaperInterval,
+		reaperBatch:          conf.ReaperBatch,
 	}
 }
 
@@ -62,20 +115,41 @@ func (s *MessageService) StopConsumer() {
 	}
 }
 
-func (s *MessageService) ProcessKafkaMessage(ctx context.Context, message entity.Message) error {
+// redelivered events are not broadcast again
+func (s *MessageService) ProcessKafkaMessage(ctx context.Context, event string, message entity.Message) error {
 	slog.Info("Processing message from Kafka",
+		"event", event,
 		"message_id", message.ID,
 		"chat_id", message.ChatID,
 		"user_id", message.UserID)
 
+	switch event {
+	case kafka.EventMessageEdited:
+		return s.processEditedMessage(ctx, message)
+	case kafka.EventMessageDeleted:
+		return s.processDeletedMessage(message)
+	}
+
+	// redelivered event of message which was broadcast already is skipped
+	current, err := s.repo.GetMessageByID(ctx, message.ID)
+	if err != nil {
+		slog.Warn("message of event not found, skipping", "message_id", message.ID, "err", err)
+		return nil
+	}
+	if current.BroadcastAt != nil {
+		slog.Info("message already broadcast, skipping", "message_id", message.ID, "broadcast_at", current.BroadcastAt)
+		return nil
+	}
+
 	wsMessage := map[string]interface{}{
-		"type":         "new_message",
+		"type":         kafka.EventNewMessage,
 		"message_id":   message.ID,
 		"chat_id":      message.ChatID,
 		"user_id":      message.UserID,
 		"content":      message.Content,
+		"entities":     message.Entities,
 		"message_type": message.Type,
-		"status":       entity.MessageStatusDelivered,
+		"status":       current.Status,
 		"client_id":    message.ClientID,
 		"timestamp":    message.CreatedAt,
 	}
@@ -87,60 +161,85 @@ func (s *MessageService) ProcessKafkaMessage(ctx context.Context, message entity
 		wsMessage["mime_type"] = message.MimeType
 	}
 
-	messageBytes, err := json.Marshal(wsMessage)
+	if message.ExpiresAt != nil {
+		wsMessage["expires_at"] = message.ExpiresAt
+	}
+
+	if len(message.Mentions) > 0 {
+		wsMessage["mentions"] = message.Mentions
+	}
+
+	if message.ForwardedFromMessageID != nil {
+		wsMessage["forwarded_from_chat_id"] = message.ForwardedFromChatID
+		wsMessage["forwarded_from_message_id"] = message.ForwardedFromMessageID
+		wsMessage["forwarded_from_user_id"] = message.ForwardedFromUserID
+	}
+
+	messageBytes, err := ws.MarshalEvent(wsMessage)
 	if err != nil {
 		slog.Error("failed to marshal WebSocket message", "err", err, "message", message)
 		return errors.New("failed marshal message json to byte")
 	}
 
-	if err := s.wsService.BroadcastMessage(messageBytes); err != nil {
+	// status becomes delivered when socket of recipient gets message
+	if err := s.wsService.BroadcastChatMessage(message.ChatID, message.ID, messageBytes); err != nil {
 		slog.Warn("Failed to broadcast WebSocket message",
 			"error", err,
 			"chat_id", message.ChatID)
 	}
 
-	err = s.repo.UpdateMessageStatus(ctx, message.ID, entity.MessageStatusDelivered)
+	err = s.repo.MarkBroadcast(ctx, message.ID)
 	if err != nil {
-		return errors.New("failed to update message status: " + err.Error())
+		return err
 	}
+	s.notifyParticipants(&message)
 
 	slog.Info("Message processed successfully",
 		"message_id", message.ID,
-		"chat_id", message.ChatID,
-		"status", entity.MessageStatusDelivered)
+		"chat_id", message.ChatID)
 
 	return nil
 }
 
-func (s *MessageService) SendMessage(ctx context.Context, userID string, req request.CreateMessage) error {
+// session is connection of sender which already knows about message, it gets no ack
+func (s *MessageService) SendMessage(ctx context.Context, userID, session string, req request.CreateMessage) (*entity.Message, error) {
 	id, err := strconv.ParseUint(userID, 10, 32)
 	if err != nil {
 		slog.Error("failed parse user_id to uint", "user_id", userID)
-		return errors.New("failed parse user_id")
+		return nil, errors.New("failed parse user_id")
 	}
 	chatID, err := strconv.ParseUint(req.ChatID, 10, 32)
 	if err != nil {
 		slog.Error("failed parse chat_id to uint", "chat_id", userID)
-		return errors.New("failed parse chat_id")
+		return nil, errors.New("failed parse chat_id")
 	}
 
 	// get chat
 	chat, err := s.chatRepo.GetChatById(uint(chatID))
 	if err != nil {
 		slog.Error("failed get chat", "chat_id", chatID, "err", err)
-		return errors.New("failed get chat")
+		return nil, errors.New("failed get chat")
 	}
 	// get participant of this chat
 	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), uint(chatID))
 	if err != nil || participant == nil {
 		slog.Error("failed get participant", "chat_id", chatID, "user_id", id, "err", err)
-		return errors.New("this user not participant of this chat")
+		return nil, errors.New("this user not participant of this chat")
 	}
 
-	if chat.Type == entity.ChatTypeChannel {
-		if participant.Role == entity.RoleMember {
-			slog.Error("permission denied, user with role member cant send to channel", "chat_id", chatID, "user_id", id, "err", err)
-			return errors.New("permission denied, member can't send message to channel")
+	if err := checkCanPost(chat, participant); err != nil {
+		return nil, err
+	}
+
+	// reply is possible only to message of same chat
+	if req.ReplyToID != nil {
+		parent, err := s.repo.GetMessageByID(ctx, *req.ReplyToID)
+		if err != nil || parent.Expired() {
+			return nil, errors.New("reply target not found")
+		}
+		if parent.ChatID != uint(chatID) {
+			slog.Error("reply target from another chat", "chat_id", chatID, "reply_to_id", *req.ReplyToID)
+			return nil, errors.New("reply target not found in this chat")
 		}
 	}
 
@@ -149,6 +248,7 @@ func (s *MessageService) SendMessage(ctx context.Context, userID string, req req
 		UserID:    uint(id),
 		Type:      req.Type,
 		Content:   req.Content,
+		Entities:  req.Entities,
 		Status:    entity.MessageStatusSent,
 		ClientID:  req.ClientID,
 		FileURL:   req.FileURL,
@@ -156,24 +256,41 @@ func (s *MessageService) SendMessage(ctx context.Context, userID string, req req
 		FileSize:  req.FileSize,
 		MimeType:  req.MimeType,
 		ReplyToID: req.ReplyToID,
+		ExpiresAt: expiresAt(chat),
 	}
 
 	if err := message.Validate(); err != nil {
-		return err
+		return nil, err
 	}
 
-	// write to repos message with status sent(create message)
-	err = s.repo.CreateMessage(ctx, &message)
+	// write to repos message with status sent(create message),
+	// retry with same client id gets message created before
+	created, err := s.repo.CreateMessageOnce(ctx, &message)
 	if err != nil {
-		slog.Error("error create message", "chat_id", message.ChatID, "user_id", message.UserID, "err", err)
-		return errors.New("failed create message")
+		return nil, err
+	}
+	if !created {
+		if message.ChatID != uint(chatID) {
+			slog.Error("client_id already used in another chat", "chat_id", chatID, "user_id", id, "client_id", req.ClientID)
+			return nil, errors.New("clientId already used")
+		}
+		// previous attempt could fail before kafka, consumer skips duplicates
+		if message.BroadcastAt != nil || message.DeletedAt.Valid {
+			slog.Info("Message already sent", "message_id", message.ID, "chat_id", chatID, "user_id", id, "client_id", req.ClientID)
+			return &message, nil
+		}
+	}
+
+	// saved again on retry, so mentions are not lost
+	if err := s.saveMentions(ctx, &message); err != nil {
+		return nil, err
 	}
 
 	key := fmt.Sprintf("chat_%d", chatID)
 	err = s.producer.SendJSONWithRetry(ctx, key, message, 5)
 	if err != nil {
 		slog.Error("error send message to Kafka", "chat_id", message.ChatID, "user_id", message.UserID, "err", err)
-		return errors.New("failed send message to Kafka")
+		return nil, errors.New("failed send message to Kafka")
 	}
 
 	slog.Info("Message sent successfully",
@@ -182,23 +299,55 @@ func (s *MessageService) SendMessage(ctx context.Context, userID string, req req
 		"user_id", userID,
 		"client_id", req.ClientID)
 
+	s.sendAck(&message, session)
+
+	return &message, nil
+}
+
+// show outgoing message on other devices of sender
+func (s *MessageService) sendAck(message *entity.Message, session string) {
+	msg := wsmsg.MessageAckMsg{
+		Type:    "message_ack",
+		Message: message,
+	}
+	msgBytes, err := ws.MarshalEvent(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "message_id", message.ID, "user_id", message.UserID, "error", err)
+		return
+	}
+
+	if err := s.wsService.SendToUser(message.UserID, session, msgBytes); err != nil {
+		slog.Warn("Failed to send message ack", "error", err, "message_id", message.ID, "user_id", message.UserID)
+	}
+}
+
+// only admins and owner post to channel
+func checkCanPost(chat *entity.Chat, participant *entity.ChatParticipant) error {
+	if chat.Type == entity.ChatTypeChannel && participant.Role == entity.RoleMember {
+		slog.Error("permission denied, user with role member cant send to channel", "chat_id", chat.ID, "user_id", participant.UserID)
+		return errors.New("permission denied, member can't send message to channel")
+	}
 	return nil
 }
 
-func (s *MessageService) GetMessagesByChatId(userID, chatID, sinceParam string) ([]*entity.Message, error) {
+// returns page of messages and cursor for next page
+func (s *MessageService) GetMessagesByChatId(userID, chatID, sinceParam string, page request.MessagesPage) ([]*entity.Message, *uint, error) {
+	if err := page.Validate(); err != nil {
+		return nil, nil, err
+	}
 	var since *time.Time
 	if sinceParam != "" {
 		parsedSince, parseErr := time.Parse(time.RFC3339, sinceParam)
 		if parseErr != nil {
 			timestamp, parseErr := strconv.ParseInt(sinceParam, 10, 64)
 			if parseErr != nil {
-				return nil, errors.New("invalid since parameter format, use RFC3339 or Unix timestamp")
+				return nil, nil, errors.New("invalid since parameter format, use RFC3339 or Unix timestamp")
 			}
 			parsedSince = time.Unix(timestamp, 0)
 		}
 
 		if parsedSince.After(time.Now()) {
-			return nil, errors.New("since parameter cannot be in the future")
+			return nil, nil, errors.New("since parameter cannot be in the future")
 		}
 
 		since = &parsedSince
@@ -206,22 +355,118 @@ func (s *MessageService) GetMessagesByChatId(userID, chatID, sinceParam string)
 	userId, err := strconv.ParseUint(userID, 10, 32)
 	if err != nil {
 		slog.Error("failed parse user_id to uint", "user_id", userID)
-		return nil, errors.New("failed parse user_id")
+		return nil, nil, errors.New("failed parse user_id")
 	}
 	chatId, err := strconv.ParseUint(chatID, 10, 32)
 	if err != nil {
 		slog.Error("failed parse chat_id to uint", "chat_id", userID)
-		return nil, errors.New("failed parse chat_id")
+		return nil, nil, errors.New("failed parse chat_id")
 	}
 	_, err = s.chatRepo.GetChatById(uint(chatId))
 	if err != nil {
 		slog.Error("failed get chat", "chat_id", chatID, "err", err)
-		return nil, errors.New("failed get chat")
+		return nil, nil, errors.New("failed get chat")
 	}
 	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(userId), uint(chatId))
 	if err != nil || participant == nil {
 		slog.Error("failed get participant", "chat_id", chatID, "user_id", userId, "err", err)
-		return nil, errors.New("this user not participant of this chat")
+		return nil, nil, errors.New("this user not participant of this chat")
+	}
+	messages, next, err := s.chatRepo.GetMessagesByChatId(uint(chatId), since, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.enrichMessages(context.Background(), uint(userId), messages); err != nil {
+		return nil, nil, err
+	}
+	return messages, next, nil
+}
+
+// move read pointer of participant, it never goes back
+func (s *MessageService) MarkRead(ctx context.Context, userID string, req request.ReadMessagesRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return errors.New("failed parse user_id")
 	}
-	return s.chatRepo.GetMessagesByChatId(uint(chatId), since)
+	chatID, err := strconv.ParseUint(req.ChatID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse chat_id to uint", "chat_id", req.ChatID)
+		return errors.New("failed parse chat_id")
+	}
+
+	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), uint(chatID))
+	if err != nil || participant == nil {
+		slog.Error("failed get participant", "chat_id", chatID, "user_id", id, "err", err)
+		return errors.New("this user not participant of this chat")
+	}
+
+	message, err := s.repo.GetMessageByID(ctx, req.MessageID)
+	if err != nil {
+		return err
+	}
+	if message.ChatID != uint(chatID) {
+		slog.Error("message from another chat", "chat_id", chatID, "message_id", req.MessageID)
+		return errors.New("message not found in this chat")
+	}
+
+	moved, err := s.chatRepo.AdvanceLastRead(uint(id), uint(chatID), message.ID)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		slog.Debug("read pointer already ahead", "chat_id", chatID, "user_id", id, "message_id", message.ID)
+		return nil
+	}
+
+	var lastRead uint
+	if participant.LastReadMessageID != nil {
+		lastRead = *participant.LastReadMessageID
+	}
+	if err := s.repo.MarkReceiptsRead(ctx, uint(chatID), uint(id), lastRead, message.ID); err != nil {
+		return err
+	}
+
+	chat, err := s.chatRepo.GetChatById(uint(chatID))
+	if err != nil {
+		slog.Error("failed get chat", "chat_id", chatID, "err", err)
+		return errors.New("failed get chat")
+	}
+	// in direct chat peer's messages are read once user read them
+	if chat.Type == entity.ChatTypeDirect {
+		if err := s.repo.MarkMessagesRead(ctx, uint(chatID), uint(id), message.ID); err != nil {
+			return err
+		}
+	}
+
+	s.sendReadReceipt(uint(chatID), uint(id), message.ID)
+
+	slog.Debug("messages marked as read", "chat_id", chatID, "user_id", id, "message_id", message.ID)
+	return nil
+}
+
+// notify other participants of chat how far user has read
+func (s *MessageService) sendReadReceipt(chatID, userID, messageID uint) {
+	msg := wsmsg.ReadReceiptMsg{
+		Type:      "read_receipt",
+		ChatID:    chatID,
+		UserID:    userID,
+		MessageID: messageID,
+		ReadAt:    time.Now(),
+	}
+	msgBytes, err := ws.MarshalEvent(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "chat_id", chatID, "user_id", userID, "error", err)
+		return
+	}
+
+	s.wsService.BroadcastToChats(
+		[]uint{chatID},
+		strconv.FormatUint(uint64(userID), 10),
+		fmt.Sprintf("read:%d:%d", chatID, userID),
+		msgBytes,
+	)
 }
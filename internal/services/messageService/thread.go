@@ -0,0 +1,96 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+)
+
+// returns parent message and page of its direct replies
+func (s *MessageService) GetThread(ctx context.Context, userID, messageID string, page request.MessagesPage) (*entity.Message, []*entity.Message, *uint, error) {
+	if err := page.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, nil, nil, errors.New("failed parse user_id")
+	}
+	parentID, err := strconv.ParseUint(messageID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse message_id to uint", "message_id", messageID)
+		return nil, nil, nil, errors.New("failed parse message_id")
+	}
+
+	parent, err := s.repo.GetMessageByID(ctx, uint(parentID))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if parent.Expired() {
+		return nil, nil, nil, errors.New("failed get message")
+	}
+	participant, err := s.chatRepo.GetParticipantByUserIdAndChatId(uint(id), parent.ChatID)
+	if err != nil || participant == nil {
+		slog.Error("failed get participant", "chat_id", parent.ChatID, "user_id", id, "err", err)
+		return nil, nil, nil, errors.New("this user not participant of this chat")
+	}
+
+	replies, next, err := s.repo.GetThread(ctx, parent.ID, page)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := s.enrichMessages(ctx, uint(id), append([]*entity.Message{parent}, replies...)); err != nil {
+		return nil, nil, nil, err
+	}
+	return parent, replies, next, nil
+}
+
+// fill reactions, reply counts, receipts and previews of replied messages
+func (s *MessageService) enrichMessages(ctx context.Context, userID uint, messages []*entity.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]uint, 0, len(messages))
+	var parentIDs []uint
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+		if message.ReplyToID != nil {
+			parentIDs = append(parentIDs, *message.ReplyToID)
+		}
+	}
+
+	reactions, err := s.repo.GetReactions(ctx, messageIDs, userID)
+	if err != nil {
+		return err
+	}
+	replyCounts, err := s.repo.GetReplyCounts(ctx, messageIDs)
+	if err != nil {
+		return err
+	}
+	parents, err := s.repo.GetMessagesByIDs(ctx, parentIDs)
+	if err != nil {
+		return err
+	}
+	receipts, err := s.repo.GetReceiptCounts(ctx, messageIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		message.Reactions = reactions[message.ID]
+		message.ReplyCount = replyCounts[message.ID]
+		message.Receipts = receipts[message.ID]
+		if message.ReplyToID == nil {
+			continue
+		}
+		if parent, ok := parents[*message.ReplyToID]; ok {
+			message.ReplyPreview = parent.Preview()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package presenceservice
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/models/response"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+type PresenceRepositoryInterface interface {
+	TouchPresence(userID uint, session string, ttl time.Duration) (bool, error)
+	RemovePresence(userID uint, session string) (bool, error)
+	GetPresence(userIDs []uint) ([]*response.Presence, error)
+}
+
+type ChatRepositoryInterface interface {
+	GetUserChatIds(userID uint) ([]uint, error)
+}
+
+type WsServiceInterface interface {
+	// send message once to every participant of chats
+	BroadcastToChats(chatIDs []uint, exclude, key string, msg []byte) error
+}
+
+type PresenceService struct {
+	repo     PresenceRepositoryInterface
+	chatRepo ChatRepositoryInterface
+	ws       WsServiceInterface
+	ttl      time.Duration
+}
+
+func NewPresenceService(repo PresenceRepositoryInterface, chatRepo ChatRepositoryInterface, ws WsServiceInterface, ttl time.Duration) *PresenceService {
+	return &PresenceService{
+		repo:     repo,
+		chatRepo: chatRepo,
+		ws:       ws,
+		ttl:      ttl,
+	}
+}
+
+// session connected or still alive, presence expires if heartbeats stop
+func (s *PresenceService) Heartbeat(userID, session string) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return
+	}
+	becameOnline, err := s.repo.TouchPresence(uint(id), session, s.ttl)
+	if err != nil {
+		slog.Error("failed update presence", "user_id", userID, "error", err)
+		return
+	}
+	if becameOnline {
+		slog.Debug("user is online", "user_id", userID)
+		s.notify(uint(id), true, nil)
+	}
+}
+
+func (s *PresenceService) Disconnected(userID, session string) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return
+	}
+	wentOffline, err := s.repo.RemovePresence(uint(id), session)
+	if err != nil {
+		slog.Error("failed remove presence", "user_id", userID, "error", err)
+		return
+	}
+	if wentOffline {
+		slog.Debug("user is offline", "user_id", userID)
+		lastSeen := time.Now()
+		s.notify(uint(id), false, &lastSeen)
+	}
+}
+
+func (s *PresenceService) GetPresence(userIDs []uint) ([]*response.Presence, error) {
+	presence, err := s.repo.GetPresence(userIDs)
+	if err != nil {
+		slog.Error("failed get presence", "user_ids", userIDs, "error", err)
+		return nil, errors.New("failed get presence")
+	}
+	return presence, nil
+}
+
+// push presence_changed to users who share a chat with user
+func (s *PresenceService) notify(userID uint, online bool, lastSeen *time.Time) {
+	chatIDs, err := s.chatRepo.GetUserChatIds(userID)
+	if err != nil {
+		slog.Error("failed get chats of user", "user_id", userID, "error", err)
+		return
+	}
+	if len(chatIDs) == 0 {
+		return
+	}
+
+	msg := wsmsg.PresenceMsg{
+		UserID:     userID,
+		Type:       "presence_changed",
+		Online:     online,
+		LastSeenAt: lastSeen,
+	}
+	msgBytes, err := ws.MarshalEvent(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "user_id", userID, "error", err)
+		return
+	}
+
+	s.ws.BroadcastToChats(chatIDs, strconv.FormatUint(uint64(userID), 10), fmt.Sprintf("presence:%d", userID), msgBytes)
+}
@@ -2,8 +2,11 @@ package userservice
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sibhellyx/Messenger/internal/models/entity"
@@ -17,15 +20,25 @@ type UserRepositoryInterface interface {
 	UpdateProfile(profile entity.UserProfile) error
 	GetUserById(userId uint) (*entity.User, error)
 	GetFullInfoAboutUser(userId uint) (*response.UserWithProfile, error)
+	GetUsersSharingChat(userID uint, userIDs []uint) ([]uint, error)
+}
+
+// max count of users in one presence request
+const maxPresenceIds = 100
+
+type PresenceServiceInterface interface {
+	GetPresence(userIDs []uint) ([]*response.Presence, error)
 }
 
 type UserService struct {
 	repository UserRepositoryInterface
+	presence   PresenceServiceInterface
 }
 
-func NewUserService(repo UserRepositoryInterface) *UserService {
+func NewUserService(repo UserRepositoryInterface, presence PresenceServiceInterface) *UserService {
 	return &UserService{
 		repository: repo,
+		presence:   presence,
 	}
 }
 
@@ -43,13 +56,20 @@ func (s *UserService) GetUsers(search string) ([]*entity.User, error) {
 	return users, nil
 }
 
-func (s *UserService) GetUsersWithProfiles(search string) ([]*response.UserWithProfile, error) {
+func (s *UserService) GetUsersWithProfiles(userID, search string) ([]*response.UserWithProfile, error) {
+	callerID, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, errors.New("failed parse user_id")
+	}
+
 	users, err := s.repository.GetUsersWithProfiles(search)
 	if err != nil {
 		slog.Error("failed to get users from repository", "error", err)
 		return nil, err
 	}
 
+	s.setLastSeen(uint(callerID), users...)
 	return users, nil
 }
 
@@ -154,16 +174,111 @@ func compareDates(date1, date2 *time.Time) bool {
 	return date1.Equal(*date2)
 }
 
-func (s *UserService) GetFullInfoAboutUser(userID string) (*response.UserWithProfile, error) {
-	if userID == "" {
+// userID is id of caller, profileUserID is id of user whose profile is requested
+func (s *UserService) GetFullInfoAboutUser(userID, profileUserID string) (*response.UserWithProfile, error) {
+	callerID, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return nil, errors.New("failed parse user_id")
+	}
+	if profileUserID == "" {
 		slog.Error("user_id is required")
 		return nil, errors.New("user_id can't be empty")
 	}
-	id, err := strconv.ParseUint(userID, 10, 32)
+	id, err := strconv.ParseUint(profileUserID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", profileUserID)
+		return nil, errors.New("failed parse user_id")
+	}
+
+	user, err := s.repository.GetFullInfoAboutUser(uint(id))
+	if err != nil {
+		return nil, err
+	}
+
+	s.setLastSeen(uint(callerID), user)
+	return user, nil
+}
+
+// presence only of user himself and users who share chat with him
+func (s *UserService) GetPresence(userID, ids string) ([]*response.Presence, error) {
+	callerID, err := strconv.ParseUint(userID, 10, 32)
 	if err != nil {
 		slog.Error("failed parse user_id to uint", "user_id", userID)
 		return nil, errors.New("failed parse user_id")
 	}
+	if strings.TrimSpace(ids) == "" {
+		slog.Error("ids is required")
+		return nil, errors.New("ids can't be empty")
+	}
 
-	return s.repository.GetFullInfoAboutUser(uint(id))
+	parts := strings.Split(ids, ",")
+	if len(parts) > maxPresenceIds {
+		return nil, fmt.Errorf("too many ids, max %d", maxPresenceIds)
+	}
+
+	userIDs := make([]uint, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			slog.Error("failed parse user_id to uint", "user_id", part)
+			return nil, errors.New("invalid user_id in ids: " + part)
+		}
+		userIDs = append(userIDs, uint(id))
+	}
+
+	visible, err := s.repository.GetUsersSharingChat(uint(callerID), userIDs)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make(map[uint]bool, len(visible)+1)
+	allowed[uint(callerID)] = true
+	for _, id := range visible {
+		allowed[id] = true
+	}
+	userIDs = slices.DeleteFunc(userIDs, func(id uint) bool {
+		return !allowed[id]
+	})
+	if len(userIDs) == 0 {
+		return []*response.Presence{}, nil
+	}
+
+	return s.presence.GetPresence(userIDs)
+}
+
+// fill last seen time of caller himself and users who share chat with him,
+// errors only logged
+func (s *UserService) setLastSeen(callerID uint, users ...*response.UserWithProfile) {
+	if len(users) == 0 {
+		return
+	}
+	userIDs := make([]uint, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.UserID)
+	}
+
+	visible, err := s.repository.GetUsersSharingChat(callerID, userIDs)
+	if err != nil {
+		slog.Warn("failed to get users sharing chat", "user_id", callerID, "error", err)
+		return
+	}
+	if slices.Contains(userIDs, callerID) {
+		visible = append(visible, callerID)
+	}
+	if len(visible) == 0 {
+		return
+	}
+
+	presence, err := s.presence.GetPresence(visible)
+	if err != nil {
+		slog.Warn("failed to get last seen of users", "error", err)
+		return
+	}
+	lastSeen := make(map[uint]*time.Time, len(presence))
+	for _, p := range presence {
+		lastSeen[p.UserID] = p.LastSeenAt
+	}
+	for _, user := range users {
+		user.LastSeenAt = lastSeen[user.UserID]
+	}
 }
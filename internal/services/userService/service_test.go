@@ -0,0 +1,159 @@
+package userservice
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/models/response"
+)
+
+// only users sharing chat and profiles are known, other methods are not used
+type fakeUserRepo struct {
+	UserRepositoryInterface
+	shared   map[uint][]uint // user -> users sharing chat with him
+	profiles []uint
+}
+
+func (r *fakeUserRepo) GetUsersWithProfiles(search string) ([]*response.UserWithProfile, error) {
+	users := make([]*response.UserWithProfile, 0, len(r.profiles))
+	for _, id := range r.profiles {
+		users = append(users, &response.UserWithProfile{UserID: id})
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepo) GetFullInfoAboutUser(userID uint) (*response.UserWithProfile, error) {
+	return &response.UserWithProfile{UserID: userID}, nil
+}
+
+func (r *fakeUserRepo) GetUsersSharingChat(userID uint, userIDs []uint) ([]uint, error) {
+	var ids []uint
+	for _, id := range userIDs {
+		if slices.Contains(r.shared[userID], id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// every asked user was seen just now
+type fakePresence struct {
+	asked []uint
+}
+
+func (p *fakePresence) GetPresence(userIDs []uint) ([]*response.Presence, error) {
+	p.asked = append(p.asked, userIDs...)
+	now := time.Now()
+	result := make([]*response.Presence, 0, len(userIDs))
+	for _, id := range userIDs {
+		result = append(result, &response.Presence{UserID: id, Online: true, LastSeenAt: &now})
+	}
+	return result, nil
+}
+
+func TestGetPresenceOnlyOfUsersSharingChat(t *testing.T) {
+	repo := &fakeUserRepo{shared: map[uint][]uint{1: {2, 3}}}
+
+	tests := []struct {
+		name    string
+		userID  string
+		ids     string
+		want    []uint
+		wantErr bool
+	}{
+		{name: "users from common chats", userID: "1", ids: "2,3", want: []uint{2, 3}},
+		{name: "strangers are skipped", userID: "1", ids: "2,4,5", want: []uint{2}},
+		{name: "user sees himself", userID: "1", ids: "1", want: []uint{1}},
+		{name: "only strangers", userID: "1", ids: "4", want: []uint{}},
+		{name: "user without chats", userID: "9", ids: "1,2", want: []uint{}},
+		{name: "empty ids", userID: "1", ids: " ", wantErr: true},
+		{name: "invalid id", userID: "1", ids: "2,x", wantErr: true},
+		{name: "invalid caller", userID: "", ids: "2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			presence := &fakePresence{}
+			s := NewUserService(repo, presence)
+
+			result, err := s.GetPresence(tt.userID, tt.ids)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GetPresence() = %v, want error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]uint, 0, len(result))
+			for _, p := range result {
+				got = append(got, p.UserID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("presence of %v, want %v", got, tt.want)
+			}
+			for _, id := range presence.asked {
+				if !slices.Contains(tt.want, id) {
+					t.Errorf("presence of user %d was read", id)
+				}
+			}
+		})
+	}
+}
+
+func TestLastSeenOnlyOfUsersSharingChat(t *testing.T) {
+	repo := &fakeUserRepo{
+		shared:   map[uint][]uint{1: {2, 3}},
+		profiles: []uint{1, 2, 3, 4},
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		want   []uint // users with last seen time
+	}{
+		{name: "users from common chats and himself", userID: "1", want: []uint{1, 2, 3}},
+		{name: "user without chats sees only himself", userID: "4", want: []uint{4}},
+		{name: "stranger sees nobody", userID: "9", want: []uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			presence := &fakePresence{}
+			s := NewUserService(repo, presence)
+
+			users, err := s.GetUsersWithProfiles(tt.userID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []uint{}
+			for _, user := range users {
+				if user.LastSeenAt != nil {
+					got = append(got, user.UserID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("last seen of %v, want %v", got, tt.want)
+			}
+			for _, id := range presence.asked {
+				if !slices.Contains(tt.want, id) {
+					t.Errorf("presence of user %d was read", id)
+				}
+			}
+
+			for _, profileID := range repo.profiles {
+				user, err := s.GetFullInfoAboutUser(tt.userID, strconv.FormatUint(uint64(profileID), 10))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if seen := user.LastSeenAt != nil; seen != slices.Contains(tt.want, profileID) {
+					t.Errorf("last seen of user %d in profile = %v", profileID, seen)
+				}
+			}
+		})
+	}
+}
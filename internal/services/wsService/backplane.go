@@ -0,0 +1,64 @@
+package wsservice
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/backplane"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+const publishTimeout = 5 * time.Second
+
+// start receiving events of all nodes
+func (s *WsService) ListenBackplane() error {
+	return s.backplane.Subscribe(s.deliver)
+}
+
+func (s *WsService) publish(event backplane.Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := s.backplane.Publish(ctx, event); err != nil {
+		slog.Error("failed publish ws event", "kind", event.Kind, "chat_id", event.ChatID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// deliver event only to clients connected to this node
+func (s *WsService) deliver(event backplane.Event) {
+	switch event.Kind {
+	case backplane.KindChat:
+		s.hub.ChatBroadcast <- ws.ChatMessage{ChatID: event.ChatID, Data: event.Data, Exclude: event.Exclude, Seqs: event.Seqs, Key: event.Key, MessageID: event.MessageID}
+	case backplane.KindChats:
+		s.hub.MultiBroadcast <- ws.MultiChatMessage{ChatIDs: event.ChatIDs, Data: event.Data, Exclude: event.Exclude, Seqs: event.Seqs, Key: event.Key, MessageID: event.MessageID}
+	case backplane.KindJoin:
+		s.hub.Join <- ws.Membership{ChatID: event.ChatID, UserID: event.UserID}
+	case backplane.KindLeave:
+		s.hub.Leave <- ws.Membership{ChatID: event.ChatID, UserID: event.UserID}
+	case backplane.KindDropChat:
+		s.hub.DropChat <- event.ChatID
+	case backplane.KindUser:
+		s.hub.UserBroadcast <- ws.UserMessage{UserID: event.UserID, Data: event.Data, ExcludeSession: event.Session}
+	case backplane.KindUsers:
+		s.hub.UsersBroadcast <- ws.UsersMessage{UserIDs: event.UserIDs, Data: event.Data, Seqs: event.Seqs}
+	case backplane.KindCloseSession:
+		s.closeLocalSession(event.Session)
+	default:
+		slog.Warn("unknown backplane event", "kind", event.Kind)
+	}
+}
+
+func (s *WsService) closeLocalSession(uuid string) {
+	s.mu.RLock()
+	client, exists := s.clients[uuid]
+	s.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	slog.Info("Closing connection of deleted session", "user_id", client.ID, "uuid", uuid)
+	client.Close()
+}
@@ -0,0 +1,95 @@
+package wsservice
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/sibhellyx/Messenger/internal/backplane"
+)
+
+// two nodes share memory backplane like they share redis channel in production
+func TestEventsReachClientsOfOtherNode(t *testing.T) {
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}, 2: {10}, 3: {20}}}
+
+	tests := []struct {
+		name    string
+		publish func(a *WsService) error
+		want    map[string]string // user -> type of received event, empty if nothing
+	}{
+		{
+			name:    "chat event",
+			publish: func(a *WsService) error { return a.BroadcastMessage(10, []byte(`{"type":"chat"}`)) },
+			want:    map[string]string{"1": "chat", "2": "chat", "3": ""},
+		},
+		{
+			name: "event of several chats",
+			publish: func(a *WsService) error {
+				return a.BroadcastToChats([]uint{10, 20}, "1", "", []byte(`{"type":"chats"}`))
+			},
+			want: map[string]string{"1": "", "2": "chats", "3": "chats"},
+		},
+		{
+			name:    "user event",
+			publish: func(a *WsService) error { return a.SendToUser(3, "", []byte(`{"type":"user"}`)) },
+			want:    map[string]string{"1": "", "2": "", "3": "user"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := backplane.NewMemoryBackplane()
+			a := newTestService(t, bp, chats, newFakeEvents())
+			b := newTestService(t, bp, chats, newFakeEvents())
+
+			// user 1 is connected to node a, others to node b
+			peers := map[string]*websocket.Conn{
+				"1": connect(t, a, "1", "s1"),
+				"2": connect(t, b, "2", "s2"),
+				"3": connect(t, b, "3", "s3"),
+			}
+
+			if err := tt.publish(a); err != nil {
+				t.Fatal(err)
+			}
+			for user, want := range tt.want {
+				if want == "" {
+					expectNoEvent(t, peers[user])
+					continue
+				}
+				if event := readEvent(t, peers[user]); event["type"] != want {
+					t.Errorf("user %s got %v, want %s event", user, event, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMembershipChangesReachOtherNode(t *testing.T) {
+	bp := backplane.NewMemoryBackplane()
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}}}
+	a := newTestService(t, bp, chats, newFakeEvents())
+	b := newTestService(t, bp, chats, newFakeEvents())
+	peer := connect(t, b, "1", "s1")
+
+	// user added to chat 20 through node a
+	a.AddChatMember(20, 1)
+	a.BroadcastMessage(20, []byte(`{"type":"joined"}`))
+	if event := readEvent(t, peer); event["type"] != "joined" {
+		t.Errorf("got %v after join, want joined event", event)
+	}
+
+	// frames keep order, so marker sent after event shows that event was skipped
+	a.RemoveChatMember(20, 1)
+	a.BroadcastMessage(20, []byte(`{"type":"left"}`))
+	a.BroadcastMessage(10, []byte(`{"type":"marker"}`))
+	if event := readEvent(t, peer); event["type"] != "marker" {
+		t.Errorf("got %v after leave, want marker", event)
+	}
+
+	a.RemoveChat(10)
+	a.BroadcastMessage(10, []byte(`{"type":"dropped"}`))
+	a.SendToUser(1, "", []byte(`{"type":"marker"}`))
+	if event := readEvent(t, peer); event["type"] != "marker" {
+		t.Errorf("got %v after chat removed, want marker", event)
+	}
+}
@@ -0,0 +1,82 @@
+package wsservice
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+)
+
+const (
+	deliveryQueueSize     = 4096
+	deliveryBatchSize     = 200
+	deliveryFlushInterval = time.Second
+)
+
+type ReceiptRepositoryInterface interface {
+	// save delivery time of messages, existing receipts are kept
+	MarkDelivered(ctx context.Context, receipts []entity.MessageReceipt) error
+}
+
+// collects deliveries reported by write pumps and saves them in batches
+type deliveryTracker struct {
+	repo  ReceiptRepositoryInterface
+	queue chan entity.MessageReceipt
+}
+
+// record delivery of chat messages written to sockets until ctx is done
+func (s *WsService) SetReceipts(ctx context.Context, repo ReceiptRepositoryInterface) {
+	tracker := &deliveryTracker{
+		repo:  repo,
+		queue: make(chan entity.MessageReceipt, deliveryQueueSize),
+	}
+	go tracker.run(ctx)
+	s.hub.SetDeliveryHandler(tracker.delivered)
+}
+
+// called from write pumps, never blocks them
+func (t *deliveryTracker) delivered(userID string, messageID uint) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return
+	}
+	now := time.Now()
+	select {
+	case t.queue <- entity.MessageReceipt{MessageID: messageID, UserID: uint(id), DeliveredAt: &now}:
+	default:
+		slog.Warn("delivery queue is full, receipt dropped", "user_id", userID, "message_id", messageID)
+	}
+}
+
+func (t *deliveryTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(deliveryFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entity.MessageReceipt, 0, deliveryBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.repo.MarkDelivered(ctx, batch); err != nil {
+			slog.Error("failed save delivery receipts", "count", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case receipt := <-t.queue:
+			batch = append(batch, receipt)
+			if len(batch) >= deliveryBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package wsservice
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/sibhellyx/Messenger/internal/backplane"
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+)
+
+type fakeReceipts struct {
+	mu       sync.Mutex
+	receipts []entity.MessageReceipt
+}
+
+func (r *fakeReceipts) MarkDelivered(ctx context.Context, receipts []entity.MessageReceipt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.receipts = append(r.receipts, receipts...)
+	return nil
+}
+
+// message ids delivered to users, user -> message ids
+func (r *fakeReceipts) delivered() map[uint][]uint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make(map[uint][]uint)
+	for _, receipt := range r.receipts {
+		if receipt.DeliveredAt == nil {
+			continue
+		}
+		result[receipt.UserID] = append(result[receipt.UserID], receipt.MessageID)
+	}
+	return result
+}
+
+func TestDeliveryOfChatMessagesIsRecorded(t *testing.T) {
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}, 2: {10}, 3: {20}}}
+	s := newTestService(t, backplane.NewMemoryBackplane(), chats, newFakeEvents())
+	receipts := &fakeReceipts{}
+	s.SetReceipts(context.Background(), receipts)
+
+	connect(t, s, "1", "author")
+	reader := connect(t, s, "2", "reader")
+	connect(t, s, "3", "stranger")
+
+	s.BroadcastChatMessage(10, 5, []byte(`{"type":"new_message"}`))
+	// events without message are not receipts
+	s.BroadcastMessage(10, []byte(`{"type":"chat_updated"}`))
+	s.BroadcastChatMessage(10, 6, []byte(`{"type":"new_message"}`))
+	for i := 0; i < 3; i++ {
+		readEvent(t, reader)
+	}
+
+	eventually(t, func() bool { return len(receipts.delivered()[2]) == 2 }, "deliveries to reader not saved: %v", receipts.delivered())
+	got := receipts.delivered()
+	if !slices.Equal(got[2], []uint{5, 6}) {
+		t.Errorf("delivered to reader = %v, want [5 6]", got[2])
+	}
+	// author's own deliveries are skipped by repository, stranger is not in chat
+	if len(got[3]) != 0 {
+		t.Errorf("delivered to stranger = %v", got[3])
+	}
+}
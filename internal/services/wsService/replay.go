@@ -0,0 +1,110 @@
+package wsservice
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/sibhellyx/Messenger/internal/backplane"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+// number event for every recipient, save it for replay and send to all nodes
+// frames with not empty key may be coalesced for slow clients, such events
+// (typing, presence, read pointers) are ephemeral and not kept for replay
+func (s *WsService) publishToChats(chatIDs []uint, exclude, key string, messageID uint, msg []byte) error {
+	event := backplane.Event{
+		Kind:      backplane.KindChats,
+		ChatIDs:   chatIDs,
+		Exclude:   exclude,
+		Key:       key,
+		MessageID: messageID,
+		Data:      msg,
+	}
+	if key == "" {
+		event.Seqs = s.numberEvent(chatIDs, exclude, messageID, msg)
+	}
+	if len(chatIDs) == 1 {
+		event.Kind = backplane.KindChat
+		event.ChatID = chatIDs[0]
+		event.ChatIDs = nil
+	}
+	return s.publish(event)
+}
+
+// event for given users, kept for replay like chat events
+func (s *WsService) publishToUsers(userIDs []uint, msg []byte) error {
+	event := backplane.Event{
+		Kind:    backplane.KindUsers,
+		UserIDs: make([]string, 0, len(userIDs)),
+		Data:    msg,
+		Seqs:    s.appendEvents(userIDs, 0, msg),
+	}
+	for _, id := range userIDs {
+		event.UserIDs = append(event.UserIDs, strconv.FormatUint(uint64(id), 10))
+	}
+	return s.publish(event)
+}
+
+// returns userID -> seq, event is delivered without numbers if it can not be saved
+func (s *WsService) numberEvent(chatIDs []uint, exclude string, messageID uint, msg []byte) map[string]uint64 {
+	participants, err := s.chatRepo.GetChatsParticipantIds(chatIDs)
+	if err != nil {
+		slog.Error("failed get recipients of event", "chat_ids", chatIDs, "error", err)
+		return nil
+	}
+
+	recipients := make([]uint, 0, len(participants))
+	for _, id := range participants {
+		if strconv.FormatUint(uint64(id), 10) != exclude {
+			recipients = append(recipients, id)
+		}
+	}
+
+	return s.appendEvents(recipients, messageID, msg)
+}
+
+func (s *WsService) appendEvents(userIDs []uint, messageID uint, msg []byte) map[string]uint64 {
+	seqs, err := s.events.AppendEvents(userIDs, msg, messageID, s.replaySize, s.replayTTL)
+	if err != nil {
+		slog.Error("failed save event for replay", "user_ids", userIDs, "error", err)
+		return nil
+	}
+
+	result := make(map[string]uint64, len(seqs))
+	for id, seq := range seqs {
+		result[strconv.FormatUint(uint64(id), 10)] = seq
+	}
+	return result
+}
+
+// frames of events missed by client and seq of last one,
+// if they are not kept anymore client must reload state
+func (s *WsService) missedFrames(userID uint, since uint64) ([]ws.Frame, uint64) {
+	events, last, complete, err := s.events.GetEventsSince(userID, since)
+	if err != nil {
+		slog.Error("failed get missed events", "user_id", userID, "since", since, "error", err)
+	}
+	if err == nil && complete {
+		frames := make([]ws.Frame, 0, len(events))
+		for _, event := range events {
+			frame := ws.NewFrame(event.Data, event.Seq)
+			frame.MessageID = event.MessageID
+			frames = append(frames, frame)
+		}
+		slog.Debug("replay missed events", "user_id", userID, "since", since, "count", len(frames))
+		return frames, last
+	}
+
+	msg := wsmsg.ResyncMsg{
+		Type: "resync_required",
+		Seq:  last,
+	}
+	msgBytes, err := ws.MarshalEvent(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "user_id", userID, "error", err)
+		return nil, last
+	}
+	slog.Info("missed events are not kept, resync required", "user_id", userID, "since", since, "last_seq", last)
+	return []ws.Frame{{Data: msgBytes}}, last
+}
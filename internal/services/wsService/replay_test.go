@@ -0,0 +1,168 @@
+package wsservice
+
+import (
+	"testing"
+
+	"github.com/sibhellyx/Messenger/internal/backplane"
+)
+
+func TestReconnectReplaysMissedEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		since uint64
+		want  []map[string]interface{} // frames after connection_established
+	}{
+		{
+			name:  "missed events then live one",
+			since: 1,
+			want: []map[string]interface{}{
+				{"type": "event", "n": float64(2), "seq": float64(2)},
+				{"type": "event", "n": float64(3), "seq": float64(3)},
+				{"type": "live", "seq": float64(4)},
+			},
+		},
+		{
+			name:  "nothing missed",
+			since: 3,
+			want: []map[string]interface{}{
+				{"type": "live", "seq": float64(4)},
+			},
+		},
+		{
+			name:  "client knows more than server",
+			since: 10,
+			want: []map[string]interface{}{
+				{"type": "resync_required", "seq": float64(3)},
+				{"type": "live", "seq": float64(4)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}}}
+			s := newTestService(t, backplane.NewMemoryBackplane(), chats, newFakeEvents())
+
+			// sent while user was offline
+			s.BroadcastMessage(10, []byte(`{"type":"event","n":1}`))
+			s.BroadcastMessage(10, []byte(`{"type":"event","n":2}`))
+			s.BroadcastMessage(10, []byte(`{"type":"event","n":3}`))
+
+			conn, peer := connPair(t)
+			since := tt.since
+			if _, err := s.HandleConnection("1", "s1", conn, "test", "127.0.0.1", &since); err != nil {
+				t.Fatal(err)
+			}
+			if event := readEvent(t, peer); event["type"] != "connection_established" {
+				t.Fatalf("first frame = %v, want connection_established", event)
+			}
+			s.BroadcastMessage(10, []byte(`{"type":"live"}`))
+
+			for _, want := range tt.want {
+				event := readEvent(t, peer)
+				for key, value := range want {
+					if event[key] != value {
+						t.Errorf("frame = %v, want %v", event, want)
+						break
+					}
+				}
+			}
+			expectNoEvent(t, peer)
+		})
+	}
+}
+
+func TestOnlyNotKeyedEventsAreKept(t *testing.T) {
+	tests := []struct {
+		name     string
+		publish  func(s *WsService)
+		wantKept bool
+	}{
+		{
+			name:     "chat event",
+			publish:  func(s *WsService) { s.BroadcastMessage(10, []byte(`{}`)) },
+			wantKept: true,
+		},
+		{
+			name:     "chat message",
+			publish:  func(s *WsService) { s.BroadcastChatMessage(10, 5, []byte(`{}`)) },
+			wantKept: true,
+		},
+		{
+			name:     "event of several chats",
+			publish:  func(s *WsService) { s.BroadcastToChats([]uint{10}, "", "", []byte(`{}`)) },
+			wantKept: true,
+		},
+		{
+			name:     "typing",
+			publish:  func(s *WsService) { s.BroadcastToChats([]uint{10}, "2", "typing:10:2", []byte(`{}`)) },
+			wantKept: false,
+		},
+		{
+			name:     "presence",
+			publish:  func(s *WsService) { s.BroadcastToChats([]uint{10}, "2", "presence:2", []byte(`{}`)) },
+			wantKept: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := backplane.NewMemoryBackplane()
+			r := record(t, bp)
+			events := newFakeEvents()
+			chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}, 2: {10}}}
+			s := newTestService(t, bp, chats, events)
+
+			tt.publish(s)
+
+			published := r.get()
+			if len(published) != 1 {
+				t.Fatalf("published %d events, want 1", len(published))
+			}
+			kept := events.appendCalls() > 0
+			numbered := published[0].Seqs != nil
+			if kept != tt.wantKept || numbered != tt.wantKept {
+				t.Errorf("kept = %v, numbered = %v, want %v", kept, numbered, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestNotifyUsersIsNumbered(t *testing.T) {
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}, 2: {10}, 3: {10}}}
+	events := newFakeEvents()
+	s := newTestService(t, backplane.NewMemoryBackplane(), chats, events)
+
+	online := connect(t, s, "1", "online")
+	other := connect(t, s, "3", "other")
+
+	s.BroadcastMessage(10, []byte(`{"type":"new_message"}`))
+	if err := s.NotifyUsers([]uint{1, 2}, []byte(`{"type":"mention"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// online user gets mention after message in same sequence
+	for _, want := range []map[string]interface{}{
+		{"type": "new_message", "seq": float64(1)},
+		{"type": "mention", "seq": float64(2)},
+	} {
+		if event := readEvent(t, online); event["type"] != want["type"] || event["seq"] != want["seq"] {
+			t.Errorf("online got %v, want %v", event, want)
+		}
+	}
+	if event := readEvent(t, other); event["type"] != "new_message" {
+		t.Errorf("other got %v, want new_message", event)
+	}
+	expectNoEvent(t, other)
+
+	// offline user gets mention on reconnect
+	conn, peer := connPair(t)
+	since := uint64(1)
+	if _, err := s.HandleConnection("2", "later", conn, "test", "127.0.0.1", &since); err != nil {
+		t.Fatal(err)
+	}
+	readEvent(t, peer) // connection_established
+	if event := readEvent(t, peer); event["type"] != "mention" || event["seq"] != float64(2) {
+		t.Errorf("replay = %v, want mention with seq 2", event)
+	}
+}
@@ -1,24 +1,64 @@
 package wsservice
 
 import (
+	"errors"
 	"log/slog"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/sibhellyx/Messenger/internal/backplane"
+	"github.com/sibhellyx/Messenger/internal/config"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
 	"github.com/sibhellyx/Messenger/internal/ws"
 )
 
+type ChatRepositoryInterface interface {
+	// get ids of chats where user is participant
+	GetUserChatIds(userID uint) ([]uint, error)
+	// check user for participant
+	ParticipantExist(userID, chatID uint) bool
+	// get ids of users who are participants of any of chats
+	GetChatsParticipantIds(chatIDs []uint) ([]uint, error)
+}
+
+type EventRepositoryInterface interface {
+	// save event for users and return its seq for every user
+	AppendEvents(userIDs []uint, data []byte, messageID uint, maxLen int64, ttl time.Duration) (map[uint]uint64, error)
+	// events after since, false if some of them are not kept anymore
+	GetEventsSince(userID uint, since uint64) ([]wsmsg.Event, uint64, bool, error)
+}
+
+type PresenceServiceInterface interface {
+	Heartbeat(userID, session string)
+	Disconnected(userID, session string)
+}
+
 type WsService struct {
-	hub     *ws.Hub
-	clients map[string]*ws.Client // userID -> client
-	mu      sync.RWMutex
+	hub       *ws.Hub
+	backplane backplane.Backplane
+	chatRepo  ChatRepositoryInterface
+	events    EventRepositoryInterface
+	presence  PresenceServiceInterface
+	typing    *typingTracker
+	clients   map[string]*ws.Client // session uuid -> client
+	mu        sync.RWMutex
+
+	replaySize int64         // max events kept for replay per user
+	replayTTL  time.Duration // events of user kept after his last event
 }
 
-func NewWsService(hub *ws.Hub) *WsService {
+func NewWsService(hub *ws.Hub, bp backplane.Backplane, chatRepo ChatRepositoryInterface, events EventRepositoryInterface, conf config.WsConfig) *WsService {
 	service := &WsService{
-		hub:     hub,
-		clients: make(map[string]*ws.Client),
+		hub:        hub,
+		backplane:  bp,
+		chatRepo:   chatRepo,
+		events:     events,
+		replaySize: conf.ReplaySize,
+		replayTTL:  conf.ReplayTTL,
+		typing:     newTypingTracker(conf.TypingThrottle, conf.TypingTimeout),
+		clients:    make(map[string]*ws.Client),
 	}
 
 	service.StartHealthCheck(30 * time.Second)
@@ -26,15 +66,16 @@ func NewWsService(hub *ws.Hub) *WsService {
 	return service
 }
 
-func (s *WsService) HandleConnection(userID, uuid string, conn *websocket.Conn, userAgent, ipAddress string) (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *WsService) SetPresence(presence PresenceServiceInterface) {
+	s.presence = presence
+}
 
-	// close old connection
-	if existingClient, exists := s.clients[userID]; exists {
-		slog.Info("Closing existing connection for user", "user_id", userID)
-		existingClient.Close()
-		time.Sleep(100 * time.Millisecond)
+// since is last seq received by client before reconnect, nil for new connection
+func (s *WsService) HandleConnection(userID, uuid string, conn *websocket.Conn, userAgent, ipAddress string, since *uint64) (string, error) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return "", errors.New("failed parse user_id")
 	}
 
 	// creating new client
@@ -47,9 +88,44 @@ func (s *WsService) HandleConnection(userID, uuid string, conn *websocket.Conn,
 		s.hub,
 	)
 
+	// registered before chats are loaded, so join to chat created meanwhile is not lost
 	s.hub.Register <- client
-	s.clients[userID] = client
+	chatIDs, err := s.chatRepo.GetUserChatIds(uint(id))
+	if err != nil {
+		slog.Error("failed get chats of user", "user_id", userID, "error", err)
+		s.hub.Unregister <- client
+		return "", err
+	}
+	for _, chatID := range chatIDs {
+		s.hub.Join <- ws.Membership{ChatID: chatID, UserID: userID}
+	}
+	// written before live frames, which are buffered meanwhile
+	frames := []ws.Frame{{Data: s.establishedFrame(userID)}}
+	var lastSeq uint64
+	if since != nil {
+		missed, last := s.missedFrames(uint(id), *since)
+		frames = append(frames, missed...)
+		lastSeq = last
+	}
+	client.Replay(frames, lastSeq)
+
+	// lock only guards map, io of other connections is not blocked by this one
+	s.mu.Lock()
+	existingClient, exists := s.clients[uuid]
+	s.clients[uuid] = client
+	total := len(s.clients)
+	s.mu.Unlock()
+
+	// same session reconnected, close its old connection
+	if exists {
+		slog.Info("Closing existing connection for session", "user_id", userID, "uuid", uuid)
+		existingClient.Close()
+	}
+	if s.presence != nil {
+		s.presence.Heartbeat(userID, uuid)
+	}
 
+	go s.watchClient(uuid, client)
 	go client.ReadPump()
 	go client.WritePump()
 
@@ -61,17 +137,122 @@ func (s *WsService) HandleConnection(userID, uuid string, conn *websocket.Conn,
 		"uuid", uuid,
 		"user_agent", userAgent,
 		"ip_address", ipAddress,
-		"total_connections", len(s.clients))
+		"total_connections", total)
 
 	return clientID, nil
 }
 
-func (s *WsService) BroadcastMessage(msg []byte) error {
-	s.hub.Broadcast <- msg
+func (s *WsService) establishedFrame(userID string) []byte {
+	response := map[string]interface{}{
+		"type":      "connection_established",
+		"client_id": userID,
+		"user_id":   userID,
+	}
+
+	responseBytes, err := ws.MarshalEvent(response)
+	if err != nil {
+		slog.Error("failed to marshal message", "user_id", userID, "error", err)
+	}
+	return responseBytes
+}
+
+// forget client after his connection closed
+func (s *WsService) watchClient(uuid string, client *ws.Client) {
+	<-client.Done()
+
+	s.mu.Lock()
+	current, exists := s.clients[uuid]
+	removed := exists && current == client
+	if removed {
+		delete(s.clients, uuid)
+	}
+	s.mu.Unlock()
+
+	// replaced connection must not mark reconnected session offline
+	if removed && s.presence != nil {
+		s.presence.Disconnected(client.ID, uuid)
+	}
+}
+
+// close connection of session on every node, used when session deleted
+func (s *WsService) CloseSession(uuid string) {
+	s.publish(backplane.Event{Kind: backplane.KindCloseSession, Session: uuid})
+}
 
-	slog.Debug("Message broadcasted", "message_size", len(msg))
+// send message only to participants of chat
+func (s *WsService) BroadcastMessage(chatID uint, msg []byte) error {
+	err := s.publishToChats([]uint{chatID}, "", "", 0, msg)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Message broadcasted", "chat_id", chatID, "message_size", len(msg))
+	return nil
+
+}
+
+// send new chat message, its delivery to every recipient is recorded
+func (s *WsService) BroadcastChatMessage(chatID, messageID uint, msg []byte) error {
+	err := s.publishToChats([]uint{chatID}, "", "", messageID, msg)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Chat message broadcasted", "chat_id", chatID, "message_id", messageID, "message_size", len(msg))
 	return nil
+}
+
+// frames with same not empty key may replace each other for slow clients
+func (s *WsService) BroadcastToChats(chatIDs []uint, exclude, key string, msg []byte) error {
+	err := s.publishToChats(chatIDs, exclude, key, 0, msg)
+	if err != nil {
+		return err
+	}
 
+	slog.Debug("Message broadcasted to chats", "chat_ids", chatIDs, "message_size", len(msg))
+	return nil
+}
+
+// numbered event for users, e.g. notification of new message
+func (s *WsService) NotifyUsers(userIDs []uint, msg []byte) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	err := s.publishToUsers(userIDs, msg)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Message sent to users", "user_ids", userIDs, "message_size", len(msg))
+	return nil
+}
+
+// send message to other sessions of user, empty exclude sends to all of them
+func (s *WsService) SendToUser(userID uint, excludeSession string, msg []byte) error {
+	err := s.publish(backplane.Event{
+		Kind:    backplane.KindUser,
+		UserID:  strconv.FormatUint(uint64(userID), 10),
+		Session: excludeSession,
+		Data:    msg,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Message sent to user", "user_id", userID, "message_size", len(msg))
+	return nil
+}
+
+func (s *WsService) AddChatMember(chatID, userID uint) {
+	s.publish(backplane.Event{Kind: backplane.KindJoin, ChatID: chatID, UserID: strconv.FormatUint(uint64(userID), 10)})
+}
+
+func (s *WsService) RemoveChatMember(chatID, userID uint) {
+	s.publish(backplane.Event{Kind: backplane.KindLeave, ChatID: chatID, UserID: strconv.FormatUint(uint64(userID), 10)})
+}
+
+func (s *WsService) RemoveChat(chatID uint) {
+	s.publish(backplane.Event{Kind: backplane.KindDropChat, ChatID: chatID})
 }
 
 func (s *WsService) StartHealthCheck(interval time.Duration) {
@@ -91,17 +272,22 @@ func (s *WsService) checkConnectionsHealth() {
 
 	maxInactivity := 30 * time.Second
 
-	for userID, client := range s.clients {
+	for uuid, client := range s.clients {
 		if !client.IsActive() {
 			continue
 		}
 		lastActivity := client.GetLastActivity()
 		if time.Since(lastActivity) > maxInactivity {
 			slog.Info("Closing inactive connection",
-				"user_id", userID,
-				"client_id", client.ID,
+				"user_id", client.ID,
+				"uuid", uuid,
 				"inactivity_duration", time.Since(lastActivity))
 			go client.Close()
+			continue
+		}
+		// prolong presence of live session
+		if s.presence != nil {
+			go s.presence.Heartbeat(client.ID, uuid)
 		}
 	}
 }
@@ -0,0 +1,357 @@
+package wsservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sibhellyx/Messenger/internal/backplane"
+	"github.com/sibhellyx/Messenger/internal/config"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+var testConfig = config.WsConfig{
+	WriteWait:          time.Second,
+	PongWait:           time.Minute,
+	PingPeriod:         time.Minute,
+	MaxMessageSize:     4096,
+	TypingThrottle:     time.Minute,
+	TypingTimeout:      time.Minute,
+	ReplaySize:         100,
+	ReplayTTL:          time.Hour,
+	SendBuffer:         16,
+	SlowConsumerPolicy: string(ws.PolicyDropOldest),
+}
+
+// chats of users, user -> chat ids
+type fakeChatRepo struct {
+	chats  map[uint][]uint
+	loaded func(userID uint) // called after chats of user are read
+}
+
+func (r *fakeChatRepo) GetUserChatIds(userID uint) ([]uint, error) {
+	ids := r.chats[userID]
+	if r.loaded != nil {
+		r.loaded(userID)
+	}
+	return ids, nil
+}
+
+func (r *fakeChatRepo) ParticipantExist(userID, chatID uint) bool {
+	return slices.Contains(r.chats[userID], chatID)
+}
+
+func (r *fakeChatRepo) GetChatsParticipantIds(chatIDs []uint) ([]uint, error) {
+	var ids []uint
+	for userID, chats := range r.chats {
+		for _, chatID := range chatIDs {
+			if slices.Contains(chats, chatID) {
+				ids = append(ids, userID)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// replay storage in memory, keeps every event
+type fakeEvents struct {
+	mu     sync.Mutex
+	seqs   map[uint]uint64
+	events map[uint][]wsmsg.Event
+	calls  int
+}
+
+func newFakeEvents() *fakeEvents {
+	return &fakeEvents{seqs: make(map[uint]uint64), events: make(map[uint][]wsmsg.Event)}
+}
+
+func (e *fakeEvents) AppendEvents(userIDs []uint, data []byte, messageID uint, maxLen int64, ttl time.Duration) (map[uint]uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.calls++
+	result := make(map[uint]uint64, len(userIDs))
+	for _, id := range userIDs {
+		e.seqs[id]++
+		result[id] = e.seqs[id]
+		e.events[id] = append(e.events[id], wsmsg.Event{Seq: e.seqs[id], Data: data, MessageID: messageID})
+	}
+	return result, nil
+}
+
+func (e *fakeEvents) GetEventsSince(userID uint, since uint64) ([]wsmsg.Event, uint64, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events []wsmsg.Event
+	for _, event := range e.events[userID] {
+		if event.Seq > since {
+			events = append(events, event)
+		}
+	}
+	return events, e.seqs[userID], since <= e.seqs[userID], nil
+}
+
+func (e *fakeEvents) appendCalls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+type fakePresence struct {
+	mu           sync.Mutex
+	disconnected []string
+}
+
+func (p *fakePresence) Heartbeat(userID, session string) {}
+
+func (p *fakePresence) Disconnected(userID, session string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.disconnected = append(p.disconnected, userID+"/"+session)
+}
+
+func (p *fakePresence) disconnects() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.disconnected)
+}
+
+// node of cluster, nodes created with same backplane share events
+func newTestService(t *testing.T, bp backplane.Backplane, chats *fakeChatRepo, events *fakeEvents) *WsService {
+	t.Helper()
+	hub := ws.NewHub(testConfig)
+	go hub.Run()
+	s := NewWsService(hub, bp, chats, events, testConfig)
+	if err := s.ListenBackplane(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// server side of websocket connection and peer which reads what server writes
+func connPair(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { peer.Close() })
+	return <-conns, peer
+}
+
+// connect user to node, connection_established frame is read
+func connect(t *testing.T, s *WsService, userID, uuid string) *websocket.Conn {
+	t.Helper()
+	conn, peer := connPair(t)
+	if _, err := s.HandleConnection(userID, uuid, conn, "test", "127.0.0.1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if event := readEvent(t, peer); event["type"] != "connection_established" {
+		t.Fatalf("first frame = %v, want connection_established", event)
+	}
+	return peer
+}
+
+func readEvent(t *testing.T, peer *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := peer.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed read frame: %v", err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("invalid frame %s: %v", data, err)
+	}
+	return event
+}
+
+func expectNoEvent(t *testing.T, peer *websocket.Conn) {
+	t.Helper()
+	peer.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, data, err := peer.ReadMessage(); err == nil {
+		t.Fatalf("unexpected frame %s", data)
+	}
+}
+
+func eventually(t *testing.T, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSessionsOfUserReceiveChatEvents(t *testing.T) {
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}, 2: {20}}}
+	s := newTestService(t, backplane.NewMemoryBackplane(), chats, newFakeEvents())
+
+	phone := connect(t, s, "1", "phone")
+	laptop := connect(t, s, "1", "laptop")
+	other := connect(t, s, "2", "other")
+
+	if err := s.BroadcastMessage(10, []byte(`{"type":"test"}`)); err != nil {
+		t.Fatal(err)
+	}
+	for name, peer := range map[string]*websocket.Conn{"phone": phone, "laptop": laptop} {
+		if event := readEvent(t, peer); event["type"] != "test" {
+			t.Errorf("%s got %v, want test event", name, event)
+		}
+	}
+	expectNoEvent(t, other)
+}
+
+func TestJoinWhileChatsOfConnectionAreLoaded(t *testing.T) {
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}}}
+	s := newTestService(t, backplane.NewMemoryBackplane(), chats, newFakeEvents())
+	// chat created after chats of user were read, but before they are joined
+	chats.loaded = func(userID uint) { s.AddChatMember(30, userID) }
+
+	peer := connect(t, s, "1", "phone")
+
+	for _, chatID := range []uint{10, 30} {
+		if err := s.BroadcastMessage(chatID, []byte(fmt.Sprintf(`{"type":"chat_%d"}`, chatID))); err != nil {
+			t.Fatal(err)
+		}
+		if event, want := readEvent(t, peer), fmt.Sprintf("chat_%d", chatID); event["type"] != want {
+			t.Errorf("got %v, want %s event", event, want)
+		}
+	}
+}
+
+func TestReplacedConnectionKeepsSessionOnline(t *testing.T) {
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}}}
+	s := newTestService(t, backplane.NewMemoryBackplane(), chats, newFakeEvents())
+	presence := &fakePresence{}
+	s.SetPresence(presence)
+
+	old := connect(t, s, "1", "phone")
+	current := connect(t, s, "1", "phone")
+
+	// old connection of session is closed by server
+	old.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := old.ReadMessage(); err == nil {
+		t.Fatal("old connection still open")
+	}
+
+	current.Close()
+	eventually(t, func() bool { return len(presence.disconnects()) > 0 }, "session not marked offline after close")
+	// watcher of old connection has finished long ago, it must not mark session offline
+	time.Sleep(100 * time.Millisecond)
+	if got := presence.disconnects(); !slices.Equal(got, []string{"1/phone"}) {
+		t.Errorf("disconnects = %v, want [1/phone]", got)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if n := len(s.clients); n != 0 {
+		t.Errorf("clients = %d after close, want 0", n)
+	}
+}
+
+func TestConnectionsOfDifferentSessionsAreKept(t *testing.T) {
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}}}
+	s := newTestService(t, backplane.NewMemoryBackplane(), chats, newFakeEvents())
+
+	for i := 0; i < 3; i++ {
+		connect(t, s, "1", fmt.Sprintf("session-%d", i))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if n := len(s.clients); n != 3 {
+		t.Errorf("clients = %d, want 3", n)
+	}
+}
+
+// events published by all nodes
+type recorder struct {
+	mu     sync.Mutex
+	events []backplane.Event
+}
+
+func record(t *testing.T, bp backplane.Backplane) *recorder {
+	t.Helper()
+	r := &recorder{}
+	if err := bp.Subscribe(func(event backplane.Event) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, event)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func (r *recorder) get() []backplane.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+func TestSendToUserSkipsSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude string
+		want    []string // sessions which receive event
+	}{
+		{name: "other sessions of sender", exclude: "phone", want: []string{"laptop", "tablet"}},
+		{name: "all sessions", exclude: "", want: []string{"phone", "laptop", "tablet"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := backplane.NewMemoryBackplane()
+			chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}, 2: {10}}}
+			a := newTestService(t, bp, chats, newFakeEvents())
+			b := newTestService(t, bp, chats, newFakeEvents())
+
+			// sessions of user are connected to different nodes
+			peers := map[string]*websocket.Conn{
+				"phone":  connect(t, a, "1", "phone"),
+				"laptop": connect(t, a, "1", "laptop"),
+				"tablet": connect(t, b, "1", "tablet"),
+			}
+			other := connect(t, a, "2", "other")
+
+			if err := a.SendToUser(1, tt.exclude, []byte(`{"type":"message_ack"}`)); err != nil {
+				t.Fatal(err)
+			}
+			for session, peer := range peers {
+				if slices.Contains(tt.want, session) {
+					if event := readEvent(t, peer); event["type"] != "message_ack" || event["seq"] != nil {
+						t.Errorf("%s got %v, want not numbered message_ack", session, event)
+					}
+				} else {
+					expectNoEvent(t, peer)
+				}
+			}
+			expectNoEvent(t, other)
+		})
+	}
+}
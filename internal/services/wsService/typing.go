@@ -0,0 +1,159 @@
+package wsservice
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+type typingKey struct {
+	userID uint
+	chatID uint
+}
+
+type typingState struct {
+	lastSent time.Time
+	timer    *time.Timer
+}
+
+// typing indicators live only in memory, never saved and never sent to kafka
+type typingTracker struct {
+	mu       sync.Mutex
+	states   map[typingKey]*typingState
+	throttle time.Duration
+	timeout  time.Duration
+}
+
+func newTypingTracker(throttle, timeout time.Duration) *typingTracker {
+	return &typingTracker{
+		states:   make(map[typingKey]*typingState),
+		throttle: throttle,
+		timeout:  timeout,
+	}
+}
+
+// notify other participants of chat that user started or stopped typing
+func (s *WsService) SendTyping(userID string, req request.TypingRequest, typing bool) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse user_id to uint", "user_id", userID)
+		return errors.New("failed parse user_id")
+	}
+	chatID, err := strconv.ParseUint(req.ChatID, 10, 32)
+	if err != nil {
+		slog.Error("failed parse chat_id to uint", "chat_id", req.ChatID)
+		return errors.New("failed parse chat_id")
+	}
+	key := typingKey{userID: uint(id), chatID: uint(chatID)}
+
+	if !typing {
+		if s.typing.stop(key) {
+			s.broadcastTyping(key, false)
+		}
+		return nil
+	}
+
+	if s.typing.refresh(key) {
+		// indicator already shown, too early for new event
+		return nil
+	}
+
+	if !s.chatRepo.ParticipantExist(key.userID, key.chatID) {
+		return errors.New("this user not participant of this chat")
+	}
+
+	s.typing.start(key, func() {
+		slog.Debug("typing expired", "user_id", key.userID, "chat_id", key.chatID)
+		s.broadcastTyping(key, false)
+	})
+	s.broadcastTyping(key, true)
+	return nil
+}
+
+func (s *WsService) broadcastTyping(key typingKey, typing bool) {
+	msg := wsmsg.TypingMsg{
+		ChatID: key.chatID,
+		UserID: key.userID,
+		Type:   "typing",
+		Typing: typing,
+	}
+	msgBytes, err := ws.MarshalEvent(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "chat_id", key.chatID, "user_id", key.userID, "error", err)
+		return
+	}
+
+	s.publishToChats(
+		[]uint{key.chatID},
+		strconv.FormatUint(uint64(key.userID), 10),
+		fmt.Sprintf("typing:%d:%d", key.chatID, key.userID),
+		0,
+		msgBytes,
+	)
+}
+
+// prolong indicator, returns true if event was sent recently and must be throttled
+func (t *typingTracker) refresh(key typingKey) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, exists := t.states[key]
+	if !exists {
+		return false
+	}
+	state.timer.Reset(t.timeout)
+	if time.Since(state.lastSent) < t.throttle {
+		return true
+	}
+	state.lastSent = time.Now()
+	return false
+}
+
+func (t *typingTracker) start(key typingKey, onExpire func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if state, exists := t.states[key]; exists {
+		state.lastSent = time.Now()
+		state.timer.Reset(t.timeout)
+		return
+	}
+
+	state := &typingState{lastSent: time.Now()}
+	state.timer = time.AfterFunc(t.timeout, func() {
+		t.mu.Lock()
+		current, exists := t.states[key]
+		if !exists || current != state {
+			t.mu.Unlock()
+			return
+		}
+		delete(t.states, key)
+		t.mu.Unlock()
+		onExpire()
+	})
+	t.states[key] = state
+}
+
+// returns true if user was typing
+func (t *typingTracker) stop(key typingKey) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, exists := t.states[key]
+	if !exists {
+		return false
+	}
+	state.timer.Stop()
+	delete(t.states, key)
+	return true
+}
@@ -0,0 +1,89 @@
+package wsservice
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/backplane"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/wsmsg"
+)
+
+func typingEvents(t *testing.T, r *recorder) []bool {
+	t.Helper()
+	var result []bool
+	for _, event := range r.get() {
+		var msg wsmsg.TypingMsg
+		if err := json.Unmarshal(event.Data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type == "typing" {
+			result = append(result, msg.Typing)
+		}
+	}
+	return result
+}
+
+func TestSendTypingThrottle(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []bool // start or stop typing
+		want  []bool // published typing events
+	}{
+		{name: "start", steps: []bool{true}, want: []bool{true}},
+		{name: "repeated start is throttled", steps: []bool{true, true, true}, want: []bool{true}},
+		{name: "stop after start", steps: []bool{true, false}, want: []bool{true, false}},
+		{name: "stop without start", steps: []bool{false}, want: nil},
+		{name: "start again after stop", steps: []bool{true, false, true}, want: []bool{true, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := backplane.NewMemoryBackplane()
+			r := record(t, bp)
+			chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}}}
+			s := newTestService(t, bp, chats, newFakeEvents())
+
+			for _, typing := range tt.steps {
+				if err := s.SendTyping("1", request.TypingRequest{ChatID: "10"}, typing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := typingEvents(t, r); !slices.Equal(got, tt.want) {
+				t.Errorf("typing events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendTypingStopsWithoutRefresh(t *testing.T) {
+	bp := backplane.NewMemoryBackplane()
+	r := record(t, bp)
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}}}
+	s := newTestService(t, bp, chats, newFakeEvents())
+	s.typing = newTypingTracker(time.Minute, 50*time.Millisecond)
+
+	if err := s.SendTyping("1", request.TypingRequest{ChatID: "10"}, true); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return len(r.get()) == 2 }, "typing did not expire")
+	if got := typingEvents(t, r); !slices.Equal(got, []bool{true, false}) {
+		t.Errorf("typing events = %v, want [true false]", got)
+	}
+}
+
+func TestSendTypingRequiresParticipant(t *testing.T) {
+	bp := backplane.NewMemoryBackplane()
+	r := record(t, bp)
+	chats := &fakeChatRepo{chats: map[uint][]uint{1: {10}}}
+	s := newTestService(t, bp, chats, newFakeEvents())
+
+	if err := s.SendTyping("1", request.TypingRequest{ChatID: "20"}, true); err == nil {
+		t.Error("typing in foreign chat accepted")
+	}
+	if events := r.get(); len(events) != 0 {
+		t.Errorf("published %d events, want none", len(events))
+	}
+}
@@ -17,6 +17,7 @@ type AuthServiceInterface interface {
 	SignInWithoutCode(user request.LoginRequest, params request.LoginParams) (response.Tokens, error)
 	SignIn(user request.LoginRequest, params request.LoginParams) (uint, error)
 	VerifyCode(req request.VerifyCodeRequest, params request.LoginParams) (response.Tokens, error)
+	CreateWsTicket(userId, uuid string) (response.WsTicket, error)
 }
 
 type AuthHandler struct {
@@ -170,6 +171,27 @@ func (h *AuthHandler) LogoutUser(c *gin.Context) {
 	})
 }
 
+func (h *AuthHandler) CreateWsTicket(c *gin.Context) {
+	uuid, exist := c.Get("uuid")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ticket, err := h.service.CreateWsTicket(userId.(string), uuid.(string))
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticket)
+}
+
 func WrapError(c *gin.Context, err error) {
 	c.JSON(http.StatusBadRequest, gin.H{
 		"error": err.Error(),
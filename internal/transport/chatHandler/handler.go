@@ -8,13 +8,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sibhellyx/Messenger/internal/models/entity"
 	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/response"
 )
 
 type ChatServiceInterface interface {
 	CreateChat(userID string, req request.CreateChatRequest) (*entity.Chat, error)
 	DeleteChat(userID string, req request.ChatRequest) error
 	UpdateChat(userID string, req request.UpdateChatRequest) (*entity.Chat, error)
-	GetChatsUser(userID string) ([]*entity.Chat, error)
+	GetChatsUser(userID string) ([]*response.UserChat, error)
 	GetChats() ([]*entity.Chat, error)
 	SearchChatsByName(name string) ([]*entity.Chat, error)
 	AddParticipant(userID string, req request.ParticipantRequest) error
@@ -23,6 +24,9 @@ type ChatServiceInterface interface {
 	GetChatParticipants(chatID, sinceParam string) ([]*entity.ChatParticipant, error)
 	LeaveFromChat(chatID string, userID string) error
 	EnterToChat(userID string, req request.ChatRequest) error
+	PinMessage(userID string, req request.PinRequest) error
+	UnpinMessage(userID string, req request.PinRequest) error
+	GetPinnedMessages(userID, chatID string) ([]*response.PinnedMessage, error)
 }
 
 type ChatHandler struct {
@@ -347,6 +351,85 @@ func (h *ChatHandler) UpdateParticipant(c *gin.Context) {
 	})
 }
 
+// pins for admins and owner of chat
+func (h *ChatHandler) PinMessage(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.PinRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	err = h.service.PinMessage(userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": "message pinned",
+	})
+}
+
+func (h *ChatHandler) UnpinMessage(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.PinRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	err = h.service.UnpinMessage(userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": "message unpinned",
+	})
+}
+
+func (h *ChatHandler) GetPinnedMessages(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	chatID := c.Query("id")
+
+	if chatID == "" {
+		WrapError(c, errors.New("id of chat required"))
+		return
+	}
+
+	if len(chatID) > 10 {
+		WrapError(c, errors.New("id of chat too long"))
+		return
+	}
+
+	pins, err := h.service.GetPinnedMessages(userId.(string), chatID)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pins":  pins,
+		"count": len(pins),
+	})
+}
+
 func WrapError(c *gin.Context, err error) {
 	c.JSON(http.StatusBadRequest, gin.H{
 		"error": err.Error(),
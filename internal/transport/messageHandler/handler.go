@@ -9,12 +9,25 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sibhellyx/Messenger/internal/models/entity"
 	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/models/response"
 	messageservice "github.com/sibhellyx/Messenger/internal/services/messageService"
 )
 
 type MessageServiceInterface interface {
-	SendMessage(ctx context.Context, userID string, req request.CreateMessage) error
-	GetMessagesByChatId(userID, chatID, sinceParam string) ([]*entity.Message, error)
+	SendMessage(ctx context.Context, userID, session string, req request.CreateMessage) (*entity.Message, error)
+	GetMessagesByChatId(userID, chatID, sinceParam string, page request.MessagesPage) ([]*entity.Message, *uint, error)
+	EditMessage(ctx context.Context, userID string, req request.EditMessage) (*entity.Message, error)
+	DeleteMessage(ctx context.Context, userID string, req request.DeleteMessage) error
+	MarkRead(ctx context.Context, userID string, req request.ReadMessagesRequest) error
+	GetThread(ctx context.Context, userID, messageID string, page request.MessagesPage) (*entity.Message, []*entity.Message, *uint, error)
+	SearchMessages(ctx context.Context, userID string, req request.SearchMessages) ([]*response.MessageSearchResult, error)
+	ForwardMessages(ctx context.Context, userID string, req request.ForwardMessages) ([]*entity.Message, error)
+	ScheduleMessage(ctx context.Context, userID string, req request.ScheduleMessage) (*entity.ScheduledMessage, error)
+	GetScheduledMessages(ctx context.Context, userID string) ([]*entity.ScheduledMessage, error)
+	CancelScheduledMessage(ctx context.Context, userID string, req request.CancelScheduled) error
+	GetMentions(ctx context.Context, userID, chatID string) ([]*entity.Message, error)
+	AddReaction(ctx context.Context, userID string, req request.ReactionRequest) error
+	RemoveReaction(ctx context.Context, userID string, req request.ReactionRequest) error
 }
 
 type MessageHandler struct {
@@ -39,13 +52,36 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		WrapError(c, err)
 		return
 	}
-	err = h.service.SendMessage(c.Request.Context(), userId.(string), req)
+	message, err := h.service.SendMessage(c.Request.Context(), userId.(string), c.GetString("uuid"), req)
 	if err != nil {
 		WrapError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"result": "ok",
+		"message": message,
+	})
+}
+
+func (h *MessageHandler) ForwardMessages(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.ForwardMessages
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	messages, err := h.service.ForwardMessages(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"messages": messages,
+		"count":    len(messages),
 	})
 }
 
@@ -69,7 +105,232 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 		return
 	}
 
-	messages, err := h.service.GetMessagesByChatId(userId.(string), chatID, sinceParam)
+	var page request.MessagesPage
+	if err := c.ShouldBindQuery(&page); err != nil {
+		WrapError(c, errors.New("invalid before_id, after_id or limit"))
+		return
+	}
+
+	messages, next, err := h.service.GetMessagesByChatId(userId.(string), chatID, sinceParam, page)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages":    messages,
+		"count":       len(messages),
+		"next_cursor": next,
+	})
+}
+
+// replies to message, from oldest by default
+func (h *MessageHandler) GetThread(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	messageID := c.Query("id")
+	if messageID == "" {
+		WrapError(c, errors.New("id of message required"))
+		return
+	}
+
+	if len(messageID) > 10 {
+		WrapError(c, errors.New("id of message too long"))
+		return
+	}
+
+	var page request.MessagesPage
+	if err := c.ShouldBindQuery(&page); err != nil {
+		WrapError(c, errors.New("invalid before_id, after_id or limit"))
+		return
+	}
+
+	parent, replies, next, err := h.service.GetThread(c.Request.Context(), userId.(string), messageID, page)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     parent,
+		"replies":     replies,
+		"count":       len(replies),
+		"next_cursor": next,
+	})
+}
+
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req request.SearchMessages
+	if err := c.ShouldBindQuery(&req); err != nil {
+		WrapError(c, errors.New("invalid chat_id, limit or offset"))
+		return
+	}
+
+	results, err := h.service.SearchMessages(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.EditMessage
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	message, err := h.service.EditMessage(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.DeleteMessage
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	err = h.service.DeleteMessage(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "message deleted",
+	})
+}
+
+// move read pointer of user in chat
+func (h *MessageHandler) MarkRead(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.ReadMessagesRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	err = h.service.MarkRead(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "ok",
+	})
+}
+
+func (h *MessageHandler) ScheduleMessage(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.ScheduleMessage
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	scheduled, err := h.service.ScheduleMessage(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"scheduled": scheduled,
+	})
+}
+
+func (h *MessageHandler) GetScheduledMessages(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	scheduled, err := h.service.GetScheduledMessages(c.Request.Context(), userId.(string))
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"scheduled": scheduled,
+		"count":     len(scheduled),
+	})
+}
+
+func (h *MessageHandler) CancelScheduledMessage(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.CancelScheduled
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	err = h.service.CancelScheduledMessage(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "scheduled message canceled",
+	})
+}
+
+// unread messages which mention user
+func (h *MessageHandler) GetMentions(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	chatID := c.Query("chat_id")
+	if len(chatID) > 10 {
+		WrapError(c, errors.New("id of chat too long"))
+		return
+	}
+
+	messages, err := h.service.GetMentions(c.Request.Context(), userId.(string), chatID)
 	if err != nil {
 		WrapError(c, err)
 		return
@@ -81,6 +342,50 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 	})
 }
 
+func (h *MessageHandler) AddReaction(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.ReactionRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	err = h.service.AddReaction(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "ok",
+	})
+}
+
+func (h *MessageHandler) RemoveReaction(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req request.ReactionRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	err = h.service.RemoveReaction(c.Request.Context(), userId.(string), req)
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "ok",
+	})
+}
+
 func WrapError(c *gin.Context, err error) {
 	c.JSON(http.StatusBadRequest, gin.H{
 		"error": err.Error(),
@@ -14,9 +14,10 @@ import (
 
 type UserServiceInterface interface {
 	GetUsers(search string) ([]*entity.User, error)
-	GetUsersWithProfiles(search string) ([]*response.UserWithProfile, error)
+	GetUsersWithProfiles(userID, search string) ([]*response.UserWithProfile, error)
 	UpdateProfile(userId string, req request.ProfileRequest) error
-	GetFullInfoAboutUser(userID string) (*response.UserWithProfile, error)
+	GetFullInfoAboutUser(userID, profileUserID string) (*response.UserWithProfile, error)
+	GetPresence(userID, ids string) ([]*response.Presence, error)
 }
 
 type UserHandler struct {
@@ -55,14 +56,14 @@ func (h *UserHandler) UpdateUserProfile(c *gin.Context) {
 }
 
 func (h *UserHandler) GetUserProfile(c *gin.Context) {
-	_, exist := c.Get("user_id")
+	userID, exist := c.Get("user_id")
 	if !exist {
 		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
 		return
 	}
 	profileUserID := c.Query("user_id")
 
-	userInfo, err := h.service.GetFullInfoAboutUser(profileUserID)
+	userInfo, err := h.service.GetFullInfoAboutUser(userID.(string), profileUserID)
 	if err != nil {
 		slog.Error("failed to get user profile", "error", err, "user_id", profileUserID)
 		WrapError(c, err)
@@ -81,7 +82,7 @@ func (h *UserHandler) GetMyProfile(c *gin.Context) {
 		return
 	}
 
-	userInfo, err := h.service.GetFullInfoAboutUser(userID.(string))
+	userInfo, err := h.service.GetFullInfoAboutUser(userID.(string), userID.(string))
 	if err != nil {
 		slog.Error("failed to get my profile", "error", err, "user_id", userID)
 		WrapError(c, err)
@@ -176,7 +177,7 @@ func (h *UserHandler) GetUsersWithProfiles(c *gin.Context) {
 		}
 	}
 
-	users, err := h.service.GetUsersWithProfiles(search)
+	users, err := h.service.GetUsersWithProfiles(userID.(string), search)
 	if err != nil {
 		slog.Error("failed to get users", "error", err, "user_id", userID)
 		c.JSON(500, gin.H{"error": "Internal server error"})
@@ -207,6 +208,25 @@ func (h *UserHandler) GetUsersWithProfiles(c *gin.Context) {
 	})
 }
 
+func (h *UserHandler) GetPresence(c *gin.Context) {
+	userId, exist := c.Get("user_id")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	presence, err := h.service.GetPresence(userId.(string), c.Query("ids"))
+	if err != nil {
+		WrapError(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"presence": presence,
+		"count":    len(presence),
+	})
+}
+
 func WrapError(c *gin.Context, err error) {
 	c.JSON(http.StatusBadRequest, gin.H{
 		"error": err.Error(),
@@ -0,0 +1,84 @@
+package wshandler
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sibhellyx/Messenger/internal/models/entity"
+	"github.com/sibhellyx/Messenger/internal/models/request"
+	"github.com/sibhellyx/Messenger/internal/ws"
+)
+
+type MessageServiceInterface interface {
+	SendMessage(ctx context.Context, userID, session string, req request.CreateMessage) (*entity.Message, error)
+	MarkRead(ctx context.Context, userID string, req request.ReadMessagesRequest) error
+}
+
+type TypingServiceInterface interface {
+	SendTyping(userID string, req request.TypingRequest, typing bool) error
+}
+
+// handlers of operations which client sends over socket
+type CommandHandler struct {
+	messages MessageServiceInterface
+	typing   TypingServiceInterface
+}
+
+func NewCommandHandler(messages MessageServiceInterface, typing TypingServiceInterface) *CommandHandler {
+	return &CommandHandler{
+		messages: messages,
+		typing:   typing,
+	}
+}
+
+func (h *CommandHandler) Register(hub *ws.Hub) {
+	hub.Handle(ws.OpSendMessage, h.SendMessage)
+	hub.Handle(ws.OpTypingStart, h.TypingStart)
+	hub.Handle(ws.OpTypingStop, h.TypingStop)
+	hub.Handle(ws.OpMarkRead, h.MarkRead)
+}
+
+func (h *CommandHandler) SendMessage(ctx context.Context, c *ws.Client, data json.RawMessage) (interface{}, error) {
+	var req request.CreateMessage
+	if err := ws.DecodeData(data, &req); err != nil {
+		return nil, err
+	}
+	message, err := h.messages.SendMessage(ctx, c.ID, c.UUID, req)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"clientId":  req.ClientID,
+		"messageId": message.ID,
+	}, nil
+}
+
+func (h *CommandHandler) TypingStart(ctx context.Context, c *ws.Client, data json.RawMessage) (interface{}, error) {
+	return h.setTyping(c, data, true)
+}
+
+func (h *CommandHandler) TypingStop(ctx context.Context, c *ws.Client, data json.RawMessage) (interface{}, error) {
+	return h.setTyping(c, data, false)
+}
+
+func (h *CommandHandler) setTyping(c *ws.Client, data json.RawMessage, typing bool) (interface{}, error) {
+	var req request.TypingRequest
+	if err := ws.DecodeData(data, &req); err != nil {
+		return nil, err
+	}
+	if err := h.typing.SendTyping(c.ID, req, typing); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (h *CommandHandler) MarkRead(ctx context.Context, c *ws.Client, data json.RawMessage) (interface{}, error) {
+	var req request.ReadMessagesRequest
+	if err := ws.DecodeData(data, &req); err != nil {
+		return nil, err
+	}
+	if err := h.messages.MarkRead(ctx, c.ID, req); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
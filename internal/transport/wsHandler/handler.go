@@ -1,14 +1,16 @@
 package wshandler
 
 import (
-	"encoding/json"
+	"log/slog"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/sibhellyx/Messenger/internal/ws"
 )
 
 type WsServiceInterface interface {
-	HandleConnection(userID, uuid string, conn *websocket.Conn, userAgent, ipAddress string) (string, error)
+	HandleConnection(userID, uuid string, conn *websocket.Conn, userAgent, ipAddress string, since *uint64) (string, error)
 }
 
 type WsHandler struct {
@@ -20,8 +22,10 @@ func NewWsHandler(service WsServiceInterface) *WsHandler {
 	return &WsHandler{
 		service: service,
 		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			EnableCompression: true,            // permessage-deflate if client supports it
+			Subprotocols:      ws.Subprotocols, // json by default, msgpack on request
 		},
 	}
 }
@@ -38,40 +42,34 @@ func (h *WsHandler) Connect(c *gin.Context) {
 		return
 	}
 
+	// last seq received before reconnect, missed events will be replayed
+	var since *uint64
+	if sinceParam := c.Query("since_seq"); sinceParam != "" {
+		seq, err := strconv.ParseUint(sinceParam, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"error": "invalid since_seq"})
+			return
+		}
+		since = &seq
+	}
+
 	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to upgrade connection"})
 		return
 	}
 
-	clientID, err := h.service.HandleConnection(
+	// connection_established and missed events are sent by service before live events
+	_, err = h.service.HandleConnection(
 		userId.(string),
 		uuid.(string),
 		conn,
 		c.Request.UserAgent(),
 		c.ClientIP(),
+		since,
 	)
-
-	if err != nil {
-		c.JSON(500, gin.H{"error": "failed to handle connection"})
-		return
-	}
-
-	response := map[string]interface{}{
-		"type":      "connection_established",
-		"client_id": clientID,
-		"user_id":   userId.(string),
-	}
-
-	responseBytes, err := json.Marshal(response)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "failed to marshal response"})
-		return
+		slog.Error("failed to handle connection", "user_id", userId, "error", err)
+		conn.Close()
 	}
-
-	if err := conn.WriteMessage(websocket.TextMessage, responseBytes); err != nil {
-		c.JSON(500, gin.H{"error": "failed to send client_id"})
-		return
-	}
-
 }
@@ -3,16 +3,25 @@ package ws
 import (
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// frame queued for client, seq is zero for replies and frames without number
+type Frame struct {
+	Data []byte
+	Seq  uint64
+	Key  string // frames with same key replace each other when client is slow
+
+	MessageID uint // chat message delivered by frame, 0 for other events
+}
+
 type Client struct {
 	ID           string
 	UUID         string
 	Conn         *websocket.Conn
-	Send         chan []byte
 	UserAgent    string
 	LastIP       string
 	hub          *Hub
@@ -20,6 +29,14 @@ type Client struct {
 	mu           sync.RWMutex
 	isActive     bool
 	lastActivity time.Time
+
+	replay  []Frame // written before live frames
+	lastSeq uint64  // live frames up to seq are already replayed
+
+	send    *sendQueue
+	dropped atomic.Uint64 // frames discarded because client is slow
+
+	codec Codec // chosen by subprotocol, frames are queued in json
 }
 
 func NewClient(
@@ -34,13 +51,14 @@ func NewClient(
 		ID:           id,
 		UUID:         uuid,
 		Conn:         conn,
-		Send:         make(chan []byte, 256),
+		send:         newSendQueue(hub.config.SendBuffer, SlowConsumerPolicy(hub.config.SlowConsumerPolicy), hub.config.SlowConsumerGrace),
 		UserAgent:    userAgent,
 		LastIP:       lastIp,
 		hub:          hub,
 		done:         make(chan struct{}),
 		isActive:     true,
 		lastActivity: time.Now(),
+		codec:        CodecFor(conn.Subprotocol()),
 	}
 }
 
@@ -54,25 +72,66 @@ func (c *Client) IsActive() bool {
 	return c.isActive
 }
 
+// non-blocking send to client, false if buffer full or client closed
+func (c *Client) trySend(message []byte) bool {
+	return c.trySendFrame(Frame{Data: message})
+}
+
+// queue frame according to slow consumer policy,
+// false if client closed or must be disconnected
+func (c *Client) trySendFrame(frame Frame) bool {
+	dropped, ok := c.send.push(frame)
+	if dropped > 0 {
+		total := c.dropped.Add(uint64(dropped))
+		slog.Debug("frame dropped for slow client",
+			"client_id", c.ID,
+			"client_uuid", c.UUID,
+			"dropped_total", total)
+	}
+	return ok
+}
+
+// number of frames discarded because client did not read them in time
+func (c *Client) DroppedFrames() uint64 {
+	return c.dropped.Load()
+}
+
+// frames sent before live delivery, live frames up to lastSeq will be skipped.
+// must be called before WritePump
+func (c *Client) Replay(frames []Frame, lastSeq uint64) {
+	c.replay = frames
+	c.lastSeq = lastSeq
+}
+
+// close connection and unregister client, must not be called from hub
 func (c *Client) Close() {
+	if c.shutdown() {
+		c.hub.Unregister <- c
+	}
+}
+
+// close connection without unregister, returns false if already closed
+func (c *Client) shutdown() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.isActive {
+		return false
+	}
 
 	slog.Debug("Close connection",
 		"client_id", c.ID,
 		"client_uuid", c.UUID,
 		"user_agent", c.UserAgent,
 		"remote_addr", c.LastIP,
+		"dropped_frames", c.dropped.Load(),
 	)
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.isActive {
-		c.isActive = false
-		close(c.done)
-		close(c.Send)
-		c.Conn.Close()
-	}
-	c.hub.Unregister <- c
+	c.isActive = false
+	close(c.done)
+	c.send.close()
+	c.Conn.Close()
+	return true
 }
 
 func (c *Client) ReadPump() {
@@ -122,14 +181,22 @@ func (c *Client) ReadPump() {
 			"message_preview", string(c.truncateMessage(message)),
 			"message_number", messageCount)
 
-		c.hub.Broadcast <- message
+		message, err = decodeFrame(c.codec, message)
+		if err != nil {
+			slog.Debug("failed to decode frame", "client_id", c.ID, "codec", c.codec.Subprotocol(), "error", err)
+			c.reply(Reply{Type: ReplyError, Error: "invalid frame"})
+			continue
+		}
+
+		c.dispatch(message)
 	}
 }
 
 // func for truncate message for shortly preview
 func (c *Client) truncateMessage(msg []byte) []byte {
 	if len(msg) > 100 {
-		return append(msg[:100], '.', '.', '.')
+		preview := make([]byte, 0, 103)
+		return append(append(preview, msg[:100]...), '.', '.', '.')
 	}
 	return msg
 }
@@ -152,32 +219,60 @@ func (c *Client) WritePump() {
 	var consecutivePingFailures int
 	maxConsecutivePingFailures := 3
 
+	for _, frame := range c.replay {
+		if err := c.write(frame); err != nil {
+			slog.Warn("Failed to write replayed message",
+				"client_id", c.ID,
+				"seq", frame.Seq,
+				"error", err)
+			return
+		}
+	}
+	if len(c.replay) > 0 {
+		slog.Debug("Missed messages replayed",
+			"client_id", c.ID,
+			"count", len(c.replay),
+			"last_seq", c.lastSeq)
+	}
+	c.replay = nil
+
 	for {
 		select {
-		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
-			if !ok {
-				slog.Debug("Send channel closed, sending close message",
+		case <-c.send.ready:
+			if c.send.isClosed() {
+				slog.Debug("Send queue closed, sending close message",
 					"client_id", c.ID)
+				c.Conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 
-			err := c.Conn.WriteMessage(websocket.TextMessage, message)
-			if err != nil {
-				slog.Warn("Failed to write message",
+			for {
+				frame, ok := c.send.pop()
+				if !ok {
+					break
+				}
+				if frame.Seq != 0 && frame.Seq <= c.lastSeq {
+					// queued while replaying, already sent
+					continue
+				}
+
+				err := c.write(frame)
+				if err != nil {
+					slog.Warn("Failed to write message",
+						"client_id", c.ID,
+						"error", err)
+					return
+				}
+
+				sentMessages++
+				c.updateLastActivity()
+				slog.Debug("Message sent to client",
 					"client_id", c.ID,
-					"error", err)
-				return
+					"message_size", len(frame.Data),
+					"total_sent", sentMessages)
 			}
 
-			sentMessages++
-			c.updateLastActivity()
-			slog.Debug("Message sent to client",
-				"client_id", c.ID,
-				"message_size", len(message),
-				"total_sent", sentMessages)
-
 		case <-ticker.C:
 			c.Conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
 
@@ -203,25 +298,30 @@ func (c *Client) WritePump() {
 					"consecutive_failures", consecutivePingFailures)
 			}
 
-			c.Conn.SetReadDeadline(time.Now().Add(time.Millisecond * 100))
-			_, _, err = c.Conn.ReadMessage()
-			if err != nil {
-				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-					consecutivePingFailures++
-					slog.Warn("Failed to read pong response",
-						"client_id", c.ID,
-						"error", err,
-						"consecutive_failures", consecutivePingFailures)
-
-					if consecutivePingFailures >= maxConsecutivePingFailures {
-						return
-					}
-				}
-			}
 		}
 	}
 }
 
+// write frame in codec of client, small frames are not worth compressing
+func (c *Client) write(frame Frame) error {
+	data, err := encodeFrame(c.codec, frame.Data)
+	if err != nil {
+		// broken frame must not close connection
+		slog.Error("failed to encode frame", "client_id", c.ID, "codec", c.codec.Subprotocol(), "error", err)
+		return nil
+	}
+
+	c.Conn.EnableWriteCompression(len(data) >= compressionThreshold)
+	c.Conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
+	if err := c.Conn.WriteMessage(c.codec.FrameType(), data); err != nil {
+		return err
+	}
+	if frame.MessageID != 0 {
+		c.hub.delivered(c.ID, frame.MessageID)
+	}
+	return nil
+}
+
 func (c *Client) updateLastActivity() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -0,0 +1,118 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sibhellyx/Messenger/internal/config"
+)
+
+// server side of websocket connection and peer which reads what server writes
+func connPair(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { peer.Close() })
+	return <-conns, peer
+}
+
+// client with running write pump, frames are read from returned peer
+func newPumpedClient(t *testing.T, setup func(c *Client)) (*Client, *websocket.Conn) {
+	t.Helper()
+	h := NewHub(config.WsConfig{
+		WriteWait:  time.Second,
+		PingPeriod: time.Minute,
+		SendBuffer: 16,
+	})
+	conn, peer := connPair(t)
+	c := NewClient("1", "a", conn, "test", "127.0.0.1", h)
+	setup(c)
+	go c.WritePump()
+	t.Cleanup(func() { c.shutdown() })
+	return c, peer
+}
+
+func readFrames(t *testing.T, peer *websocket.Conn, n int) []string {
+	t.Helper()
+	frames := make([]string, 0, n)
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(frames) < n {
+		_, data, err := peer.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %v, then: %v", frames, err)
+		}
+		frames = append(frames, string(data))
+	}
+	return frames
+}
+
+func TestClientSkipsReplayedFrames(t *testing.T) {
+	tests := []struct {
+		name    string
+		replay  []Frame
+		lastSeq uint64
+		live    []Frame
+		want    []string
+	}{
+		{
+			name:    "live frames up to last seq are skipped",
+			replay:  []Frame{NewFrame([]byte(`{"n":4}`), 4), NewFrame([]byte(`{"n":5}`), 5)},
+			lastSeq: 5,
+			live:    []Frame{NewFrame([]byte(`{"n":4}`), 4), NewFrame([]byte(`{"n":5}`), 5), NewFrame([]byte(`{"n":6}`), 6)},
+			want:    []string{`{"seq":4,"n":4}`, `{"seq":5,"n":5}`, `{"seq":6,"n":6}`},
+		},
+		{
+			name:    "frames without seq are always sent",
+			replay:  []Frame{NewFrame([]byte(`{"n":1}`), 1)},
+			lastSeq: 1,
+			live:    []Frame{{Data: []byte(`{"type":"ack"}`)}, NewFrame([]byte(`{"n":1}`), 1)},
+			want:    []string{`{"seq":1,"n":1}`, `{"type":"ack"}`},
+		},
+		{
+			name:   "new connection sends everything",
+			replay: []Frame{{Data: []byte(`{"type":"connection_established"}`)}},
+			live:   []Frame{NewFrame([]byte(`{"n":1}`), 1), NewFrame([]byte(`{"n":2}`), 2)},
+			want:   []string{`{"type":"connection_established"}`, `{"seq":1,"n":1}`, `{"seq":2,"n":2}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, peer := newPumpedClient(t, func(c *Client) {
+				c.Replay(tt.replay, tt.lastSeq)
+				// live frames queued while replay was loaded
+				for _, frame := range tt.live {
+					c.trySendFrame(frame)
+				}
+			})
+
+			got := readFrames(t, peer, len(tt.want))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("frames = %v, want %v", got, tt.want)
+			}
+			peer.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			if _, data, err := peer.ReadMessage(); err == nil {
+				t.Errorf("unexpected frame %s", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// subprotocols which client can choose in Sec-WebSocket-Protocol,
+// in order of server preference
+const (
+	SubprotocolMsgPack = "msgpack"
+	SubprotocolJSON    = "json"
+)
+
+var Subprotocols = []string{SubprotocolMsgPack, SubprotocolJSON}
+
+// frames smaller than threshold are sent without permessage-deflate
+const compressionThreshold = 256
+
+// format of frames on the wire
+type Codec interface {
+	Subprotocol() string
+	// websocket.TextMessage or websocket.BinaryMessage
+	FrameType() int
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// events are built once in json, shared between nodes and kept for replay,
+// then transcoded for clients which use other codec
+var EventCodec Codec = JSONCodec{}
+
+func MarshalEvent(v interface{}) ([]byte, error) {
+	return EventCodec.Marshal(v)
+}
+
+// codec chosen by client, json if client did not ask for subprotocol
+func CodecFor(subprotocol string) Codec {
+	switch subprotocol {
+	case SubprotocolMsgPack:
+		return MsgPackCodec{}
+	default:
+		return JSONCodec{}
+	}
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Subprotocol() string { return SubprotocolJSON }
+
+func (JSONCodec) FrameType() int { return websocket.TextMessage }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+var (
+	msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+	// decodes json numbers without fraction as integers, not float64
+	jsonHandle = &codec.JsonHandle{}
+)
+
+func init() {
+	msgpackHandle.RawToString = true
+	msgpackHandle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	jsonHandle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+}
+
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) Subprotocol() string { return SubprotocolMsgPack }
+
+func (MsgPackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (MsgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, msgpackHandle).Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode msgpack: %w", err)
+	}
+	return out, nil
+}
+
+func (MsgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode msgpack: %w", err)
+	}
+	return nil
+}
+
+// convert json frame to format of codec
+func encodeFrame(c Codec, data []byte) ([]byte, error) {
+	if _, ok := c.(JSONCodec); ok {
+		return data, nil
+	}
+	var v interface{}
+	if err := codec.NewDecoderBytes(data, jsonHandle).Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode json frame: %w", err)
+	}
+	return c.Marshal(v)
+}
+
+// convert frame from client to json
+func decodeFrame(c Codec, data []byte) ([]byte, error) {
+	if _, ok := c.(JSONCodec); ok {
+		return data, nil
+	}
+	var v interface{}
+	if err := c.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.NewEncoder(&out).Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode json frame: %w", err)
+	}
+	return bytes.TrimRight(out.Bytes(), "\n"), nil
+}
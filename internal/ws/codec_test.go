@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCodecFor(t *testing.T) {
+	tests := []struct {
+		subprotocol string
+		want        Codec
+		frameType   int
+	}{
+		{subprotocol: SubprotocolMsgPack, want: MsgPackCodec{}, frameType: websocket.BinaryMessage},
+		{subprotocol: SubprotocolJSON, want: JSONCodec{}, frameType: websocket.TextMessage},
+		{subprotocol: "", want: JSONCodec{}, frameType: websocket.TextMessage},
+		{subprotocol: "xml", want: JSONCodec{}, frameType: websocket.TextMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subprotocol, func(t *testing.T) {
+			c := CodecFor(tt.subprotocol)
+			if c != tt.want || c.FrameType() != tt.frameType {
+				t.Errorf("CodecFor(%q) = %T with frame type %d, want %T with %d", tt.subprotocol, c, c.FrameType(), tt.want, tt.frameType)
+			}
+		})
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	frames := []string{
+		`{"seq":18446744073709551615,"type":"new_message","message":{"id":42,"content":"привет","entities":[{"type":"bold","offset":0,"length":6}]}}`,
+		`{"type":"presence","online":true,"last_seen":null}`,
+		`{"op":"send","ref":"a-1","data":{"chat_id":7,"rate":1.5}}`,
+		`[]`,
+	}
+
+	for _, codec := range []Codec{JSONCodec{}, MsgPackCodec{}} {
+		for _, frame := range frames {
+			t.Run(codec.Subprotocol(), func(t *testing.T) {
+				encoded, err := encodeFrame(codec, []byte(frame))
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded, err := decodeFrame(codec, encoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var got, want interface{}
+				if err := json.Unmarshal(decoded, &got); err != nil {
+					t.Fatalf("invalid json %s: %v", decoded, err)
+				}
+				json.Unmarshal([]byte(frame), &want)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("round trip = %s, want %s", decoded, frame)
+				}
+			})
+		}
+	}
+}
+
+func TestMsgPackKeepsIntegers(t *testing.T) {
+	encoded, err := encodeFrame(MsgPackCodec{}, []byte(`{"seq":18446744073709551615,"chat_id":7,"rate":1.5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err := (MsgPackCodec{}).Unmarshal(encoded, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if seq, ok := v["seq"].(uint64); !ok || seq != 18446744073709551615 {
+		t.Errorf("seq = %T %v, want uint64 max", v["seq"], v["seq"])
+	}
+	switch id := v["chat_id"].(type) {
+	case int64:
+		if id != 7 {
+			t.Errorf("chat_id = %d, want 7", id)
+		}
+	case uint64:
+		if id != 7 {
+			t.Errorf("chat_id = %d, want 7", id)
+		}
+	default:
+		t.Errorf("chat_id = %T, want integer", v["chat_id"])
+	}
+	if rate, ok := v["rate"].(float64); !ok || rate != 1.5 {
+		t.Errorf("rate = %T %v, want float64 1.5", v["rate"], v["rate"])
+	}
+}
+
+func TestDecodeInvalidFrame(t *testing.T) {
+	if _, err := decodeFrame(MsgPackCodec{}, []byte{0xc1}); err == nil {
+		t.Error("invalid msgpack decoded")
+	}
+	if _, err := encodeFrame(MsgPackCodec{}, []byte(`{"a":`)); err == nil {
+		t.Error("invalid json encoded")
+	}
+}
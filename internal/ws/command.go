@@ -0,0 +1,104 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// operations which client can send over socket
+const (
+	OpSendMessage = "message.send"
+	OpTypingStart = "typing.start"
+	OpTypingStop  = "typing.stop"
+	OpMarkRead    = "message.read"
+	OpPing        = "ping"
+)
+
+// types of replies for client frames
+const (
+	ReplyAck   = "ack"
+	ReplyError = "error"
+)
+
+const commandTimeout = 10 * time.Second
+
+// frame from client
+type Envelope struct {
+	Op   string          `json:"op"`
+	Data json.RawMessage `json:"data,omitempty"`
+	Ref  json.RawMessage `json:"ref,omitempty"`
+}
+
+// answer on client frame, ref copied from envelope
+type Reply struct {
+	Type  string          `json:"type"`
+	Op    string          `json:"op,omitempty"`
+	Ref   json.RawMessage `json:"ref,omitempty"`
+	Data  interface{}     `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// handler of one operation, returned value is sent in ack
+type CommandHandler func(ctx context.Context, c *Client, data json.RawMessage) (interface{}, error)
+
+// register handler for operation, must be called before serving connections
+func (h *Hub) Handle(op string, handler CommandHandler) {
+	h.handlers[op] = handler
+}
+
+func handlePing(ctx context.Context, c *Client, data json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"pong": time.Now().Unix(),
+	}, nil
+}
+
+func (c *Client) dispatch(message []byte) {
+	var env Envelope
+	if err := json.Unmarshal(message, &env); err != nil || env.Op == "" {
+		slog.Debug("invalid frame from client", "client_id", c.ID, "error", err)
+		c.reply(Reply{Type: ReplyError, Error: "invalid frame, expected {\"op\": ..., \"data\": ..., \"ref\": ...}"})
+		return
+	}
+
+	handler, ok := c.hub.handlers[env.Op]
+	if !ok {
+		c.reply(Reply{Type: ReplyError, Op: env.Op, Ref: env.Ref, Error: "unknown op"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	result, err := handler(ctx, c, env.Data)
+	if err != nil {
+		slog.Debug("command failed", "client_id", c.ID, "op", env.Op, "error", err)
+		c.reply(Reply{Type: ReplyError, Op: env.Op, Ref: env.Ref, Error: err.Error()})
+		return
+	}
+	c.reply(Reply{Type: ReplyAck, Op: env.Op, Ref: env.Ref, Data: result})
+}
+
+func (c *Client) reply(r Reply) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		slog.Error("failed to marshal reply", "client_id", c.ID, "op", r.Op, "error", err)
+		return
+	}
+	if !c.trySend(data) {
+		slog.Warn("failed to send reply, buffer full or connection closed", "client_id", c.ID, "op", r.Op)
+	}
+}
+
+// decode data of command
+func DecodeData(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return errors.New("data is required")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("invalid data: " + err.Error())
+	}
+	return nil
+}
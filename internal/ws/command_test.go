@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sibhellyx/Messenger/internal/config"
+)
+
+func TestClientDispatch(t *testing.T) {
+	h := NewHub(config.WsConfig{SendBuffer: 16})
+	h.Handle("echo", func(ctx context.Context, c *Client, data json.RawMessage) (interface{}, error) {
+		var v map[string]interface{}
+		if err := DecodeData(data, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+	h.Handle("fail", func(ctx context.Context, c *Client, data json.RawMessage) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+
+	tests := []struct {
+		name  string
+		frame string
+		want  Reply
+	}{
+		{
+			name:  "not json",
+			frame: `hello`,
+			want:  Reply{Type: ReplyError},
+		},
+		{
+			name:  "op is required",
+			frame: `{"data":{}}`,
+			want:  Reply{Type: ReplyError},
+		},
+		{
+			name:  "unknown op",
+			frame: `{"op":"nope","ref":1}`,
+			want:  Reply{Type: ReplyError, Op: "nope", Ref: json.RawMessage(`1`), Error: "unknown op"},
+		},
+		{
+			name:  "handler result is sent in ack",
+			frame: `{"op":"echo","data":{"a":"b"},"ref":"x"}`,
+			want:  Reply{Type: ReplyAck, Op: "echo", Ref: json.RawMessage(`"x"`), Data: map[string]interface{}{"a": "b"}},
+		},
+		{
+			name:  "missing data",
+			frame: `{"op":"echo","ref":2}`,
+			want:  Reply{Type: ReplyError, Op: "echo", Ref: json.RawMessage(`2`), Error: "data is required"},
+		},
+		{
+			name:  "handler error",
+			frame: `{"op":"fail","ref":3}`,
+			want:  Reply{Type: ReplyError, Op: "fail", Ref: json.RawMessage(`3`), Error: "failed"},
+		},
+		{
+			name:  "ping",
+			frame: `{"op":"ping"}`,
+			want:  Reply{Type: ReplyAck, Op: OpPing},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(h, "1", "a")
+			c.dispatch([]byte(tt.frame))
+
+			frames := drain(c)
+			if len(frames) != 1 {
+				t.Fatalf("replies = %v, want one", frames)
+			}
+			var got struct {
+				Type  string                 `json:"type"`
+				Op    string                 `json:"op"`
+				Ref   json.RawMessage        `json:"ref"`
+				Data  map[string]interface{} `json:"data"`
+				Error string                 `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(frames[0]), &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Type != tt.want.Type || got.Op != tt.want.Op || string(got.Ref) != string(tt.want.Ref) {
+				t.Errorf("reply = %s, want %+v", frames[0], tt.want)
+			}
+			if tt.want.Error != "" && got.Error != tt.want.Error {
+				t.Errorf("error = %q, want %q", got.Error, tt.want.Error)
+			}
+			if tt.want.Type == ReplyError && got.Error == "" {
+				t.Errorf("error reply without message: %s", frames[0])
+			}
+			if data, ok := tt.want.Data.(map[string]interface{}); ok && got.Data["a"] != data["a"] {
+				t.Errorf("data = %v, want %v", got.Data, data)
+			}
+		})
+	}
+}
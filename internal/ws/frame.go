@@ -0,0 +1,19 @@
+package ws
+
+import "strconv"
+
+// frame with seq field added to json object, data is left as is without seq
+func NewFrame(data []byte, seq uint64) Frame {
+	if seq == 0 || len(data) < 2 || data[0] != '{' {
+		return Frame{Data: data}
+	}
+
+	framed := make([]byte, 0, len(data)+32)
+	framed = append(framed, `{"seq":`...)
+	framed = strconv.AppendUint(framed, seq, 10)
+	if data[1] != '}' {
+		framed = append(framed, ',')
+	}
+	framed = append(framed, data[1:]...)
+	return Frame{Data: framed, Seq: seq}
+}
@@ -0,0 +1,28 @@
+package ws
+
+import "testing"
+
+func TestNewFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		seq     uint64
+		want    string
+		wantSeq uint64
+	}{
+		{name: "seq added to object", data: `{"type":"a"}`, seq: 3, want: `{"seq":3,"type":"a"}`, wantSeq: 3},
+		{name: "empty object", data: `{}`, seq: 1, want: `{"seq":1}`, wantSeq: 1},
+		{name: "zero seq keeps data", data: `{"type":"a"}`, seq: 0, want: `{"type":"a"}`},
+		{name: "not object keeps data", data: `[1,2]`, seq: 5, want: `[1,2]`},
+		{name: "too short", data: `{`, seq: 5, want: `{`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := NewFrame([]byte(tt.data), tt.seq)
+			if string(frame.Data) != tt.want || frame.Seq != tt.wantSeq {
+				t.Errorf("NewFrame() = %s seq %d, want %s seq %d", frame.Data, frame.Seq, tt.want, tt.wantSeq)
+			}
+		})
+	}
+}
@@ -6,22 +6,89 @@ import (
 	"github.com/sibhellyx/Messenger/internal/config"
 )
 
+// message for participants of one chat
+type ChatMessage struct {
+	ChatID    uint
+	Data      []byte
+	Exclude   string            // user who will not receive message
+	Seqs      map[string]uint64 // userID -> seq of event for user
+	Key       string            // frames with same key may replace each other
+	MessageID uint              // chat message in event, its delivery is reported
+}
+
+// message for participants of several chats, every user receives it once
+type MultiChatMessage struct {
+	ChatIDs   []uint
+	Data      []byte
+	Exclude   string
+	Seqs      map[string]uint64
+	Key       string
+	MessageID uint
+}
+
+// message for all sessions of several users, numbered for every user
+type UsersMessage struct {
+	UserIDs []string
+	Data    []byte
+	Seqs    map[string]uint64
+}
+
+// message for sessions of one user, not numbered and not replayed
+type UserMessage struct {
+	UserID         string
+	Data           []byte
+	ExcludeSession string // session which will not receive message
+}
+
+// link between connected user and chat
+type Membership struct {
+	ChatID uint
+	UserID string
+}
+
 type Hub struct {
-	Clients    map[*Client]bool
-	Broadcast  chan []byte
-	Register   chan *Client
-	Unregister chan *Client
+	Clients        map[*Client]bool
+	ChatBroadcast  chan ChatMessage
+	MultiBroadcast chan MultiChatMessage
+	UserBroadcast  chan UserMessage
+	UsersBroadcast chan UsersMessage
+	Register       chan *Client
+	Unregister     chan *Client
+	Join           chan Membership
+	Leave          chan Membership
+	DropChat       chan uint
+
+	users     map[string]map[*Client]bool // userID -> clients
+	chats     map[uint]map[string]bool    // chatID -> userIDs
+	userChats map[string]map[uint]bool    // userID -> chatIDs
+
+	handlers map[string]CommandHandler // op -> handler of client frames
+
+	// called from write pumps when frame with chat message is written
+	onDelivered func(userID string, messageID uint)
 
 	config config.WsConfig
 }
 
 func NewHub(conf config.WsConfig) *Hub {
 	return &Hub{
-		Broadcast:  make(chan []byte),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
-		Clients:    make(map[*Client]bool),
-		config:     conf,
+		ChatBroadcast:  make(chan ChatMessage),
+		MultiBroadcast: make(chan MultiChatMessage),
+		UserBroadcast:  make(chan UserMessage),
+		UsersBroadcast: make(chan UsersMessage),
+		Register:       make(chan *Client),
+		Unregister:     make(chan *Client),
+		Join:           make(chan Membership),
+		Leave:          make(chan Membership),
+		DropChat:       make(chan uint),
+		Clients:        make(map[*Client]bool),
+		users:          make(map[string]map[*Client]bool),
+		chats:          make(map[uint]map[string]bool),
+		userChats:      make(map[string]map[uint]bool),
+		handlers: map[string]CommandHandler{
+			OpPing: handlePing,
+		},
+		config: conf,
 	}
 }
 
@@ -31,20 +98,144 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.Register:
 			slog.Info("register", "user_id", client.ID)
-			h.Clients[client] = true
+			h.addClient(client)
 		case client := <-h.Unregister:
 			slog.Info("unregister", "user_id", client.ID)
-			delete(h.Clients, client)
-		case message := <-h.Broadcast:
-			for client := range h.Clients {
-				slog.Info("broadcast", "message", string(message), "recived_id", client.ID)
-				select {
-				case client.Send <- message:
-				default:
-					client.Close()
-					delete(h.Clients, client)
+			h.removeClient(client)
+		case m := <-h.Join:
+			h.join(m)
+		case m := <-h.Leave:
+			h.leave(m)
+		case chatID := <-h.DropChat:
+			h.dropChat(chatID)
+		case message := <-h.ChatBroadcast:
+			for userID := range h.chats[message.ChatID] {
+				if userID == message.Exclude {
+					continue
+				}
+				frame := NewFrame(message.Data, message.Seqs[userID])
+				frame.Key = message.Key
+				frame.MessageID = message.MessageID
+				for client := range h.users[userID] {
+					slog.Debug("chat broadcast", "chat_id", message.ChatID, "recived_id", client.ID)
+					h.send(client, frame)
 				}
 			}
+		case message := <-h.MultiBroadcast:
+			recipients := make(map[string]bool)
+			for _, chatID := range message.ChatIDs {
+				for userID := range h.chats[chatID] {
+					recipients[userID] = true
+				}
+			}
+			delete(recipients, message.Exclude)
+			for userID := range recipients {
+				frame := NewFrame(message.Data, message.Seqs[userID])
+				frame.Key = message.Key
+				frame.MessageID = message.MessageID
+				for client := range h.users[userID] {
+					h.send(client, frame)
+				}
+			}
+		case message := <-h.UserBroadcast:
+			frame := NewFrame(message.Data, 0)
+			for client := range h.users[message.UserID] {
+				if client.UUID == message.ExcludeSession {
+					continue
+				}
+				h.send(client, frame)
+			}
+		case message := <-h.UsersBroadcast:
+			for _, userID := range message.UserIDs {
+				frame := NewFrame(message.Data, message.Seqs[userID])
+				for client := range h.users[userID] {
+					h.send(client, frame)
+				}
+			}
+		}
+	}
+}
+
+// must be set before clients connect, handler must not block
+func (h *Hub) SetDeliveryHandler(handler func(userID string, messageID uint)) {
+	h.onDelivered = handler
+}
+
+func (h *Hub) delivered(userID string, messageID uint) {
+	if h.onDelivered != nil {
+		h.onDelivered(userID, messageID)
+	}
+}
+
+func (h *Hub) send(client *Client, frame Frame) {
+	if client.trySendFrame(frame) {
+		return
+	}
+	// hub can not wait for own Unregister, forget client right here
+	if client.shutdown() {
+		slog.Info("slow client disconnected", "user_id", client.ID, "uuid", client.UUID, "dropped_frames", client.DroppedFrames())
+	}
+	h.removeClient(client)
+}
+
+func (h *Hub) addClient(client *Client) {
+	h.Clients[client] = true
+	if h.users[client.ID] == nil {
+		h.users[client.ID] = make(map[*Client]bool)
+	}
+	h.users[client.ID][client] = true
+}
+
+func (h *Hub) removeClient(client *Client) {
+	delete(h.Clients, client)
+
+	clients, ok := h.users[client.ID]
+	if !ok {
+		return
+	}
+	delete(clients, client)
+	if len(clients) > 0 {
+		return
+	}
+	// last connection of user closed, forget his chats
+	delete(h.users, client.ID)
+	for chatID := range h.userChats[client.ID] {
+		h.leave(Membership{ChatID: chatID, UserID: client.ID})
+	}
+	delete(h.userChats, client.ID)
+}
+
+// index only connected users, others will be loaded on connect
+func (h *Hub) join(m Membership) {
+	if _, online := h.users[m.UserID]; !online {
+		return
+	}
+	if h.chats[m.ChatID] == nil {
+		h.chats[m.ChatID] = make(map[string]bool)
+	}
+	h.chats[m.ChatID][m.UserID] = true
+
+	if h.userChats[m.UserID] == nil {
+		h.userChats[m.UserID] = make(map[uint]bool)
+	}
+	h.userChats[m.UserID][m.ChatID] = true
+}
+
+func (h *Hub) leave(m Membership) {
+	if members, ok := h.chats[m.ChatID]; ok {
+		delete(members, m.UserID)
+		if len(members) == 0 {
+			delete(h.chats, m.ChatID)
 		}
 	}
+	if chats, ok := h.userChats[m.UserID]; ok {
+		delete(chats, m.ChatID)
+	}
+}
+
+func (h *Hub) dropChat(chatID uint) {
+	for userID := range h.chats[chatID] {
+		delete(h.userChats[userID], chatID)
+	}
+	delete(h.chats, chatID)
 }
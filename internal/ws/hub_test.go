@@ -0,0 +1,248 @@
+package ws
+
+import (
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/sibhellyx/Messenger/internal/config"
+)
+
+func newTestHub(t *testing.T) *Hub {
+	t.Helper()
+	h := NewHub(config.WsConfig{SendBuffer: 16, SlowConsumerPolicy: string(PolicyDropOldest)})
+	go h.Run()
+	return h
+}
+
+// client without connection, frames stay in its queue
+func newTestClient(h *Hub, userID, uuid string) *Client {
+	return &Client{
+		ID:       userID,
+		UUID:     uuid,
+		hub:      h,
+		done:     make(chan struct{}),
+		isActive: true,
+		send:     newSendQueue(h.config.SendBuffer, PolicyDropOldest, 0),
+	}
+}
+
+// hub handles channels one by one, so after this returns previous operations are done
+func settle(h *Hub) {
+	h.DropChat <- 0
+}
+
+func drain(c *Client) []string {
+	var data []string
+	for {
+		frame, ok := c.send.pop()
+		if !ok {
+			return data
+		}
+		data = append(data, string(frame.Data))
+	}
+}
+
+// users 1 and 2 in chat 10, users 2 and 3 in chat 20, user 4 without chats
+func newRoutingHub(t *testing.T) (*Hub, map[string]*Client) {
+	t.Helper()
+	h := newTestHub(t)
+	clients := make(map[string]*Client)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		clients[id] = newTestClient(h, id, "session-"+id)
+		h.Register <- clients[id]
+	}
+	for _, m := range []Membership{{10, "1"}, {10, "2"}, {20, "2"}, {20, "3"}} {
+		h.Join <- m
+	}
+	return h, clients
+}
+
+func TestHubRoutesToChatMembers(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(h *Hub, clients map[string]*Client)
+		want []string // users who received one frame
+	}{
+		{
+			name: "chat message reaches only its members",
+			run: func(h *Hub, clients map[string]*Client) {
+				h.ChatBroadcast <- ChatMessage{ChatID: 10, Data: []byte(`{}`)}
+			},
+			want: []string{"1", "2"},
+		},
+		{
+			name: "excluded user is skipped",
+			run: func(h *Hub, clients map[string]*Client) {
+				h.ChatBroadcast <- ChatMessage{ChatID: 10, Data: []byte(`{}`), Exclude: "1"}
+			},
+			want: []string{"2"},
+		},
+		{
+			name: "member of several chats receives message once",
+			run: func(h *Hub, clients map[string]*Client) {
+				h.MultiBroadcast <- MultiChatMessage{ChatIDs: []uint{10, 20}, Data: []byte(`{}`)}
+			},
+			want: []string{"1", "2", "3"},
+		},
+		{
+			name: "user who left chat is skipped",
+			run: func(h *Hub, clients map[string]*Client) {
+				h.Leave <- Membership{ChatID: 10, UserID: "1"}
+				h.ChatBroadcast <- ChatMessage{ChatID: 10, Data: []byte(`{}`)}
+			},
+			want: []string{"2"},
+		},
+		{
+			name: "dropped chat has no members",
+			run: func(h *Hub, clients map[string]*Client) {
+				h.DropChat <- 20
+				h.ChatBroadcast <- ChatMessage{ChatID: 20, Data: []byte(`{}`)}
+			},
+			want: nil,
+		},
+		{
+			name: "unknown chat reaches nobody",
+			run: func(h *Hub, clients map[string]*Client) {
+				h.ChatBroadcast <- ChatMessage{ChatID: 30, Data: []byte(`{}`)}
+			},
+			want: nil,
+		},
+		{
+			name: "join of offline user is ignored",
+			run: func(h *Hub, clients map[string]*Client) {
+				h.Join <- Membership{ChatID: 10, UserID: "5"}
+				clients["5"] = newTestClient(h, "5", "session-5")
+				h.Register <- clients["5"]
+				h.ChatBroadcast <- ChatMessage{ChatID: 10, Data: []byte(`{}`)}
+			},
+			want: []string{"1", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, clients := newRoutingHub(t)
+			tt.run(h, clients)
+			settle(h)
+
+			var got []string
+			for id, client := range clients {
+				switch n := len(drain(client)); n {
+				case 0:
+				case 1:
+					got = append(got, id)
+				default:
+					t.Errorf("user %s received %d frames, want 1", id, n)
+				}
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("receivers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubNumbersFramesPerUser(t *testing.T) {
+	h, clients := newRoutingHub(t)
+	h.ChatBroadcast <- ChatMessage{
+		ChatID: 10,
+		Data:   []byte(`{"type":"new_message"}`),
+		Seqs:   map[string]uint64{"1": 7, "2": 3},
+	}
+	settle(h)
+
+	want := map[string]string{
+		"1": `{"seq":7,"type":"new_message"}`,
+		"2": `{"seq":3,"type":"new_message"}`,
+	}
+	for id, data := range want {
+		got := drain(clients[id])
+		if len(got) != 1 || got[0] != data {
+			t.Errorf("user %s frames = %v, want [%s]", id, got, data)
+		}
+	}
+}
+
+func TestHubDeliversToEverySessionOfUser(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(h *Hub, a, b *Client)
+		want []string // sessions which received one frame
+	}{
+		{
+			name: "chat message reaches all sessions",
+			run: func(h *Hub, a, b *Client) {
+				h.ChatBroadcast <- ChatMessage{ChatID: 10, Data: []byte(`{}`)}
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "user message skips excluded session",
+			run: func(h *Hub, a, b *Client) {
+				h.UserBroadcast <- UserMessage{UserID: "1", Data: []byte(`{}`), ExcludeSession: "a"}
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "user message without exclude reaches all sessions",
+			run: func(h *Hub, a, b *Client) {
+				h.UserBroadcast <- UserMessage{UserID: "1", Data: []byte(`{}`)}
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "closed session keeps chats of user for other one",
+			run: func(h *Hub, a, b *Client) {
+				h.Unregister <- a
+				h.ChatBroadcast <- ChatMessage{ChatID: 10, Data: []byte(`{}`)}
+			},
+			want: []string{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHub(t)
+			a := newTestClient(h, "1", "a")
+			b := newTestClient(h, "1", "b")
+			h.Register <- a
+			h.Register <- b
+			h.Join <- Membership{ChatID: 10, UserID: "1"}
+
+			tt.run(h, a, b)
+			settle(h)
+
+			var got []string
+			for _, client := range []*Client{a, b} {
+				if n := len(drain(client)); n == 1 {
+					got = append(got, client.UUID)
+				} else if n > 1 {
+					t.Errorf("session %s received %d frames, want 1", client.UUID, n)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("receivers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubForgetsChatsAfterLastSession(t *testing.T) {
+	h := newTestHub(t)
+	a := newTestClient(h, "1", "a")
+	h.Register <- a
+	h.Join <- Membership{ChatID: 10, UserID: "1"}
+	h.Unregister <- a
+
+	// reconnected user gets chats again on connect, until then nothing is routed
+	b := newTestClient(h, "1", "b")
+	h.Register <- b
+	h.ChatBroadcast <- ChatMessage{ChatID: 10, Data: []byte(`{}`)}
+	settle(h)
+
+	if got := drain(b); len(got) != 0 {
+		t.Errorf("new session received %v before joining chats", got)
+	}
+}
@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+// what to do when client does not read frames fast enough and his buffer is full
+type SlowConsumerPolicy string
+
+const (
+	// discard oldest queued frame to make room for new one
+	PolicyDropOldest SlowConsumerPolicy = "drop_oldest"
+	// new frame replaces queued frame with same key (typing, presence),
+	// otherwise oldest frame is discarded
+	PolicyCoalesce SlowConsumerPolicy = "coalesce"
+	// discard new frames, disconnect if buffer stays full during grace period
+	PolicyDisconnect SlowConsumerPolicy = "disconnect"
+)
+
+// bounded queue of frames for one client, written by hub and read by WritePump
+type sendQueue struct {
+	mu        sync.Mutex
+	frames    []Frame
+	size      int
+	policy    SlowConsumerPolicy
+	grace     time.Duration
+	fullSince time.Time
+	closed    bool
+	ready     chan struct{} // signaled when frames added or queue closed
+}
+
+func newSendQueue(size int, policy SlowConsumerPolicy, grace time.Duration) *sendQueue {
+	return &sendQueue{
+		frames: make([]Frame, 0, size),
+		size:   size,
+		policy: policy,
+		grace:  grace,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// add frame to queue, returns number of discarded frames
+// and false if queue closed or client must be disconnected
+func (q *sendQueue) push(frame Frame) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return 0, false
+	}
+
+	dropped := 0
+	if len(q.frames) >= q.size {
+		switch q.policy {
+		case PolicyCoalesce:
+			if i := q.indexOf(frame.Key); i >= 0 {
+				q.frames[i] = frame
+				return 1, true
+			}
+			q.dropOldest()
+			dropped = 1
+		case PolicyDisconnect:
+			if q.fullSince.IsZero() {
+				q.fullSince = time.Now()
+			}
+			if time.Since(q.fullSince) > q.grace {
+				return 1, false
+			}
+			return 1, true
+		default:
+			q.dropOldest()
+			dropped = 1
+		}
+	}
+
+	q.frames = append(q.frames, frame)
+	q.signal()
+	return dropped, true
+}
+
+// oldest queued frame, false if queue is empty
+func (q *sendQueue) pop() (Frame, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.frames) == 0 {
+		return Frame{}, false
+	}
+	frame := q.frames[0]
+	q.dropOldest()
+	q.fullSince = time.Time{}
+	return frame, true
+}
+
+func (q *sendQueue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.signal()
+}
+
+func (q *sendQueue) indexOf(key string) int {
+	if key == "" {
+		return -1
+	}
+	for i := len(q.frames) - 1; i >= 0; i-- {
+		if q.frames[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *sendQueue) dropOldest() {
+	copy(q.frames, q.frames[1:])
+	q.frames[len(q.frames)-1] = Frame{}
+	q.frames = q.frames[:len(q.frames)-1]
+}
+
+func (q *sendQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
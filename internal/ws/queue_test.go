@@ -0,0 +1,200 @@
+package ws
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sibhellyx/Messenger/internal/config"
+)
+
+func TestSendQueuePolicies(t *testing.T) {
+	frame := func(data, key string) Frame {
+		return Frame{Data: []byte(data), Key: key}
+	}
+
+	tests := []struct {
+		name        string
+		policy      SlowConsumerPolicy
+		fullSince   time.Duration // how long queue is already full, 0 if not
+		pushes      []Frame       // queue of size 2
+		want        []string      // frames left in queue
+		wantDropped int           // by all pushes
+		wantOk      bool          // of last push
+	}{
+		{
+			name:   "queue not full",
+			policy: PolicyDropOldest,
+			pushes: []Frame{frame("a", ""), frame("b", "")},
+			want:   []string{"a", "b"},
+			wantOk: true,
+		},
+		{
+			name:        "drop oldest",
+			policy:      PolicyDropOldest,
+			pushes:      []Frame{frame("a", ""), frame("b", ""), frame("c", "")},
+			want:        []string{"b", "c"},
+			wantDropped: 1,
+			wantOk:      true,
+		},
+		{
+			name:        "drop oldest ignores keys",
+			policy:      PolicyDropOldest,
+			pushes:      []Frame{frame("a", "typing"), frame("b", ""), frame("c", "typing")},
+			want:        []string{"b", "c"},
+			wantDropped: 1,
+			wantOk:      true,
+		},
+		{
+			name:        "coalesce replaces frame with same key",
+			policy:      PolicyCoalesce,
+			pushes:      []Frame{frame("a", "typing"), frame("b", ""), frame("c", "typing")},
+			want:        []string{"c", "b"},
+			wantDropped: 1,
+			wantOk:      true,
+		},
+		{
+			name:        "coalesce drops oldest without same key",
+			policy:      PolicyCoalesce,
+			pushes:      []Frame{frame("a", "typing"), frame("b", ""), frame("c", "presence")},
+			want:        []string{"b", "c"},
+			wantDropped: 1,
+			wantOk:      true,
+		},
+		{
+			name:        "coalesce drops oldest for frame without key",
+			policy:      PolicyCoalesce,
+			pushes:      []Frame{frame("a", ""), frame("b", ""), frame("c", "")},
+			want:        []string{"b", "c"},
+			wantDropped: 1,
+			wantOk:      true,
+		},
+		{
+			name:        "disconnect discards new frame during grace",
+			policy:      PolicyDisconnect,
+			pushes:      []Frame{frame("a", ""), frame("b", ""), frame("c", "")},
+			want:        []string{"a", "b"},
+			wantDropped: 1,
+			wantOk:      true,
+		},
+		{
+			name:        "disconnect after grace",
+			policy:      PolicyDisconnect,
+			fullSince:   2 * time.Minute,
+			pushes:      []Frame{frame("a", ""), frame("b", ""), frame("c", "")},
+			want:        []string{"a", "b"},
+			wantDropped: 1,
+			wantOk:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newSendQueue(2, tt.policy, time.Minute)
+			if tt.fullSince > 0 {
+				q.fullSince = time.Now().Add(-tt.fullSince)
+			}
+
+			dropped, ok := 0, false
+			for _, f := range tt.pushes {
+				var n int
+				n, ok = q.push(f)
+				dropped += n
+			}
+
+			var got []string
+			for {
+				f, more := q.pop()
+				if !more {
+					break
+				}
+				got = append(got, string(f.Data))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("queue = %v, want %v", got, tt.want)
+			}
+			if dropped != tt.wantDropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.wantDropped)
+			}
+			if ok != tt.wantOk {
+				t.Errorf("push ok = %v, want %v", ok, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSendQueueGraceRestartsAfterRead(t *testing.T) {
+	q := newSendQueue(1, PolicyDisconnect, time.Minute)
+	q.push(Frame{Data: []byte("a")})
+	q.push(Frame{Data: []byte("b")})
+	q.fullSince = time.Now().Add(-2 * time.Minute)
+
+	// client has read frame, so it is not stuck
+	q.pop()
+	q.push(Frame{Data: []byte("c")})
+	if _, ok := q.push(Frame{Data: []byte("d")}); !ok {
+		t.Error("client disconnected after reading queue")
+	}
+}
+
+func TestClosedSendQueueRejectsFrames(t *testing.T) {
+	q := newSendQueue(2, PolicyDropOldest, 0)
+	q.close()
+	if _, ok := q.push(Frame{Data: []byte("a")}); ok {
+		t.Error("push to closed queue succeeded")
+	}
+	if !q.isClosed() {
+		t.Error("queue not closed")
+	}
+}
+
+func TestHubDisconnectsSlowClient(t *testing.T) {
+	h := NewHub(config.WsConfig{SendBuffer: 1, SlowConsumerPolicy: string(PolicyDisconnect)})
+	go h.Run()
+
+	// write pump of slow client is not started, so it reads nothing
+	conn, peer := connPair(t)
+	slow := NewClient("1", "slow", conn, "test", "127.0.0.1", h)
+	fast := newTestClient(h, "2", "fast")
+	fast.send = newSendQueue(16, PolicyDropOldest, 0)
+	h.Register <- slow
+	h.Register <- fast
+	h.Join <- Membership{ChatID: 10, UserID: "1"}
+	h.Join <- Membership{ChatID: 10, UserID: "2"}
+
+	for i := 0; i < 3; i++ {
+		h.ChatBroadcast <- ChatMessage{ChatID: 10, Data: []byte(`{}`)}
+		time.Sleep(time.Millisecond)
+	}
+	settle(h)
+
+	select {
+	case <-slow.done:
+	default:
+		t.Fatal("slow client is not closed")
+	}
+	if h.Clients[slow] {
+		t.Error("slow client is still registered")
+	}
+	if n := len(drain(fast)); n != 3 {
+		t.Errorf("fast client received %d frames, want 3", n)
+	}
+
+	// read pump of closed client unregisters it, hub must not block on it
+	done := make(chan struct{})
+	go func() {
+		h.Unregister <- slow
+		settle(h)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("unregister of disconnected client blocked")
+	}
+
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := peer.ReadMessage(); err == nil {
+		t.Error("connection of slow client is open")
+	}
+}